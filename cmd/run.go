@@ -7,7 +7,9 @@ import (
 	"github.com/mojixcoder/caster/internal/app"
 	"github.com/mojixcoder/caster/internal/cache"
 	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/loader"
 	"github.com/mojixcoder/caster/internal/server"
+	"github.com/mojixcoder/caster/internal/writebehind"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -25,13 +27,19 @@ func run(cmd *cobra.Command, args []string) {
 	app.Init()
 
 	cache := cache.NewLRUCache()
+	cache.StartExpiry()
 
 	cluster, err := cluster.NewCluster()
 	if err != nil {
 		app.App.Logger.Fatal("error in creating the cluster", zap.Error(err))
 	}
 
-	srv := server.NewServer(cache, cluster)
+	loader := loader.NewLoader(cache)
+
+	writeBehind := writebehind.NewQueue(cache)
+	writeBehind.Start()
+
+	srv := server.NewServer(cache, cluster, loader, writeBehind)
 
 	if err := srv.RunServer(); err != nil {
 		if err != http.ErrServerClosed {
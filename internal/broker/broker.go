@@ -0,0 +1,73 @@
+package broker
+
+import "sync"
+
+type (
+	// Subscription receives the published messages which pass its filter.
+	Subscription[T any] struct {
+		// C is the channel messages are delivered on.
+		// It's closed when the subscription is canceled.
+		C <-chan T
+
+		ch     chan T
+		filter func(T) bool
+	}
+
+	// Broker fans out published messages to its subscribers.
+	// Publishing never blocks, messages are dropped for subscribers that can't keep up.
+	Broker[T any] struct {
+		mutex *sync.RWMutex
+		subs  map[*Subscription[T]]struct{}
+	}
+)
+
+// New returns a new broker.
+func New[T any]() *Broker[T] {
+	return &Broker[T]{
+		mutex: new(sync.RWMutex),
+		subs:  make(map[*Subscription[T]]struct{}),
+	}
+}
+
+// Subscribe adds a new subscriber with the given buffer size.
+// A nil filter receives every message.
+func (b *Broker[T]) Subscribe(buffer int, filter func(T) bool) *Subscription[T] {
+	ch := make(chan T, buffer)
+	sub := &Subscription[T]{C: ch, ch: ch, filter: filter}
+
+	b.mutex.Lock()
+	b.subs[sub] = struct{}{}
+	b.mutex.Unlock()
+
+	return sub
+}
+
+// Unsubscribe removes the subscriber and closes its channel.
+func (b *Broker[T]) Unsubscribe(sub *Subscription[T]) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+
+	delete(b.subs, sub)
+	close(sub.ch)
+}
+
+// Publish publishes a message to the subscribers.
+func (b *Broker[T]) Publish(msg T) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(msg) {
+			continue
+		}
+
+		select {
+		case sub.ch <- msg:
+		default:
+		}
+	}
+}
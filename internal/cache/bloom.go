@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"errors"
+	"math"
+
+	"github.com/mojixcoder/caster/internal/app"
+)
+
+// maxBloomBits is the maximum number of bits of a Bloom filter, it's 32MB.
+// The bits are allocated before the cache can check if they fit, so a single request can't allocate more.
+const maxBloomBits = 1 << 28
+
+// ErrInvalidBloomParams is raised when a Bloom filter is created with invalid parameters.
+var ErrInvalidBloomParams = errors.New("capacity must be positive and error rate must be between 0 and 1")
+
+// BloomFilter is a probabilistic set which tells if an item has definitely not been added,
+// or has probably been added.
+type BloomFilter struct {
+	bits   []uint64
+	m      uint64
+	hashes uint64
+}
+
+// Verifying interface compliance.
+var _ Value = (*BloomFilter)(nil)
+
+// NewBloomFilter returns a new Bloom filter sized for the expected number of items and false positive rate.
+// It returns ErrTooLarge if the filter needs more than maxBloomBits bits, or more than the cache's memory limit.
+func NewBloomFilter(capacity uint64, errorRate float64) (*BloomFilter, error) {
+	if capacity == 0 || errorRate <= 0 || errorRate >= 1 {
+		return nil, ErrInvalidBloomParams
+	}
+
+	limit := uint64(maxBloomBits)
+	if maxMemory := app.App.Config.Caster.MaxMemory; maxMemory > 0 && maxMemory < maxBloomBits/8 {
+		limit = maxMemory * 8
+	}
+
+	m := math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	if m > float64(limit) {
+		return nil, ErrTooLarge
+	}
+
+	hashes := uint64(math.Round(m / float64(capacity) * math.Ln2))
+	if hashes == 0 {
+		hashes = 1
+	}
+
+	return &BloomFilter{
+		bits:   make([]uint64, (uint64(m)+63)/64),
+		m:      uint64(m),
+		hashes: hashes,
+	}, nil
+}
+
+// Kind returns the data type of the value.
+func (b *BloomFilter) Kind() Kind {
+	return KindBloomFilter
+}
+
+// Size returns the memory used by the bits in bytes.
+func (b *BloomFilter) Size() uint64 {
+	return uint64(len(b.bits)) * 8
+}
+
+// locations returns the bit locations of an item using double hashing.
+func (b *BloomFilter) locations(item string) func(i uint64) uint64 {
+	h1 := hash64(item)
+	h2 := mix64(h1) | 1
+
+	return func(i uint64) uint64 {
+		return (h1 + i*h2) % b.m
+	}
+}
+
+// Add adds the item and reports whether it's new, i.e. it wasn't probably added before.
+func (b *BloomFilter) Add(item string) bool {
+	location := b.locations(item)
+
+	var added bool
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := location(i)
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			b.bits[bit/64] |= 1 << (bit % 64)
+			added = true
+		}
+	}
+
+	return added
+}
+
+// Has reports whether the item has probably been added.
+func (b *BloomFilter) Has(item string) bool {
+	location := b.locations(item)
+
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := location(i)
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,13 +1,150 @@
 package cache
 
+import (
+	"time"
+
+	"github.com/mojixcoder/caster/internal/hlc"
+)
+
 // Cache is the cache algorithm and can be implemented by various algorithms.
 type Cache interface {
 	// Get gets a key from cache.
 	Get(key string) (any, error)
 
+	// GetItem gets a key from cache along with its metadata.
+	GetItem(key string) (Item, error)
+
 	// Set sets a key-value pair to the cache.
 	Set(key string, val any) error
 
+	// SetItem sets a key and its metadata to the cache.
+	SetItem(key string, item Item) error
+
+	// Update atomically updates the value of a key.
+	Update(key string, fn UpdateFunc) error
+
+	// View atomically reads the value of a key.
+	View(key string, fn ViewFunc) error
+
+	// Atomic runs fn while no other operation can be performed on the cache.
+	Atomic(fn func(tx Tx) error) error
+
+	// Delete deletes a key from the cache.
+	Delete(key string) error
+
 	// Flush flushes the cache.
 	Flush() error
+
+	// SetListener sets the listener which is notified about every change in the cache.
+	SetListener(listener Listener)
+
+	// SetClock sets the clock which timestamps the writes without a timestamp.
+	SetClock(clock func() hlc.Timestamp)
+}
+
+// Item is a cached value along with its metadata.
+type Item struct {
+	// Value is the cached value.
+	Value Value
+
+	// Version is the version of the item, it's increased by every write in the cache.
+	Version uint64
+
+	// Timestamp is the hybrid logical clock timestamp of the write which set the item, zero if it's unknown.
+	// Writes without a timestamp are timestamped by the cache's clock, if it has one.
+	Timestamp hlc.Timestamp
+
+	// SoftExpiry is the time after which the item is stale. Zero means it never goes stale.
+	SoftExpiry time.Time
+
+	// HardExpiry is the time after which the item is expired. Zero means it never expires.
+	HardExpiry time.Time
+
+	// size is the memory accounted for the value when it was stored.
+	size uint64
+}
+
+// NewItem returns a new item which goes stale after softTTL and expires after hardTTL.
+// Zero TTLs are ignored. If only hardTTL is given, the item goes stale when it expires.
+func NewItem(val Value, softTTL, hardTTL time.Duration) Item {
+	item := Item{Value: val}
+	now := time.Now()
+
+	if hardTTL > 0 {
+		item.HardExpiry = now.Add(hardTTL)
+		item.SoftExpiry = item.HardExpiry
+	}
+	if softTTL > 0 {
+		item.SoftExpiry = now.Add(softTTL)
+	}
+
+	return item
 }
+
+// IsStale determines if the item is stale at the given time.
+func (i Item) IsStale(now time.Time) bool {
+	return !i.SoftExpiry.IsZero() && !now.Before(i.SoftExpiry)
+}
+
+// IsExpired determines if the item is expired at the given time.
+func (i Item) IsExpired(now time.Time) bool {
+	return !i.HardExpiry.IsZero() && !now.Before(i.HardExpiry)
+}
+
+// UpdateFunc receives the current value of a key, nil if it doesn't exist, and returns its new value.
+// It may modify the value in place. Returning nil deletes the key and returning an error leaves it untouched,
+// unless the value has already been modified in place.
+type UpdateFunc func(val Value) (Value, error)
+
+// ViewFunc receives the current value of a key. It must not modify the value or keep it after returning.
+type ViewFunc func(val Value) error
+
+// Tx gives access to the cache inside Atomic. It must not be used after Atomic returns.
+type Tx interface {
+	// Get returns the value of a key, nil if it doesn't exist. It must not be modified.
+	Get(key string) Value
+
+	// GetItem returns the item of a key and whether it exists. Its value must not be modified.
+	GetItem(key string) (Item, bool)
+
+	// Fits determines if the values fit in the cache together, by their number and their memory.
+	// Writing values which fit together can't evict each other, as keys written in a transaction are evicted last.
+	Fits(vals ...Value) bool
+
+	// SetItem sets the item of a key.
+	SetItem(key string, item Item) error
+
+	// Delete deletes a key and reports whether it existed.
+	Delete(key string) bool
+}
+
+// Op is the operation which changed a key.
+type Op string
+
+const (
+	// OpSet is sent when a key is set.
+	OpSet Op = "set"
+
+	// OpDelete is sent when a key is deleted.
+	OpDelete Op = "delete"
+
+	// OpExpired is sent when an expired key is removed.
+	OpExpired Op = "expired"
+
+	// OpEvicted is sent when a key is evicted to make room for another one.
+	OpEvicted Op = "evicted"
+
+	// OpFlush is sent when the cache is flushed. It has no key.
+	OpFlush Op = "flush"
+)
+
+// Event is a change in the cache.
+type Event struct {
+	Key     string `json:"key"`
+	Op      Op     `json:"op"`
+	Version uint64 `json:"version"`
+}
+
+// Listener is notified about changes in the cache.
+// It's called while the cache is locked, so it must not block or call the cache.
+type Listener func(Event)
@@ -0,0 +1,116 @@
+package cache
+
+import "math"
+
+// Hash is a map of fields to JSON values.
+type Hash struct {
+	fields map[string]any
+	size   uint64
+}
+
+// Verifying interface compliance.
+var _ Value = (*Hash)(nil)
+
+// NewHash returns a new empty hash.
+func NewHash() *Hash {
+	return &Hash{fields: make(map[string]any)}
+}
+
+// Kind returns the data type of the value.
+func (h *Hash) Kind() Kind {
+	return KindHash
+}
+
+// Size returns the approximate memory used by the hash in bytes, it's the sum of its fields' sizes.
+func (h *Hash) Size() uint64 {
+	return h.size
+}
+
+// Len returns the number of fields.
+func (h *Hash) Len() int {
+	return len(h.fields)
+}
+
+// Get returns the value of a field.
+func (h *Hash) Get(field string) (any, bool) {
+	val, ok := h.fields[field]
+	return val, ok
+}
+
+// Set sets the value of a field and reports whether the field is new.
+func (h *Hash) Set(field string, val any) bool {
+	old, exists := h.fields[field]
+	if exists {
+		h.size -= fieldSize(field, old)
+	}
+
+	h.fields[field] = val
+	h.size += fieldSize(field, val)
+
+	return !exists
+}
+
+// Delete deletes a field and reports whether it existed.
+func (h *Hash) Delete(field string) bool {
+	old, exists := h.fields[field]
+	if !exists {
+		return false
+	}
+
+	delete(h.fields, field)
+	h.size -= fieldSize(field, old)
+
+	return true
+}
+
+// All returns a copy of all fields.
+func (h *Hash) All() map[string]any {
+	fields := make(map[string]any, len(h.fields))
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+// IncrBy increments the integer value of a field by delta and returns the new value.
+// A missing field is treated as zero. It returns ErrOverflow if the new value doesn't fit in an int64.
+func (h *Hash) IncrBy(field string, delta int64) (int64, error) {
+	var cur int64
+
+	if val, ok := h.fields[field]; ok {
+		n, ok := toInt64(val)
+		if !ok {
+			return 0, ErrNotInteger
+		}
+		cur = n
+	}
+
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	h.Set(field, cur+delta)
+
+	return cur + delta, nil
+}
+
+// fieldSize returns the memory accounted for a field.
+func fieldSize(field string, val any) uint64 {
+	return uint64(len(field)) + sizeOf(val)
+}
+
+// toInt64 converts a JSON number to an integer, if it's integral.
+func toInt64(val any) (int64, bool) {
+	switch v := val.(type) {
+	case int64:
+		return v, true
+	case float64:
+		// float64(math.MaxInt64) rounds up to 2^63, which doesn't fit, unlike float64(math.MinInt64) which is exact.
+		if v != math.Trunc(v) || v >= math.MaxInt64 || v < math.MinInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	default:
+		return 0, false
+	}
+}
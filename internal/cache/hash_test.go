@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToInt64(t *testing.T) {
+	tests := []struct {
+		name string
+		val  any
+		n    int64
+		ok   bool
+	}{
+		{name: "int64", val: int64(42), n: 42, ok: true},
+		{name: "integral float", val: float64(-7), n: -7, ok: true},
+		{name: "fraction", val: 1.5},
+		{name: "min", val: float64(math.MinInt64), n: math.MinInt64, ok: true},
+		{name: "max rounds up", val: float64(math.MaxInt64)},
+		{name: "below min", val: -1e19},
+		{name: "string", val: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, ok := toInt64(tt.val)
+			if n != tt.n || ok != tt.ok {
+				t.Errorf("got %d %t, want %d %t", n, ok, tt.n, tt.ok)
+			}
+		})
+	}
+}
+
+func TestHashIncrBy(t *testing.T) {
+	tests := []struct {
+		name  string
+		start any
+		delta int64
+		n     int64
+		err   error
+	}{
+		{name: "missing field", delta: 5, n: 5},
+		{name: "increment", start: int64(1), delta: 2, n: 3},
+		{name: "decrement", start: float64(1), delta: -2, n: -1},
+		{name: "up to max", start: int64(math.MaxInt64 - 1), delta: 1, n: math.MaxInt64},
+		{name: "down to min", start: int64(math.MinInt64 + 1), delta: -1, n: math.MinInt64},
+		{name: "overflow", start: int64(math.MaxInt64), delta: 1, err: ErrOverflow},
+		{name: "underflow", start: int64(math.MinInt64), delta: -1, err: ErrOverflow},
+		{name: "not an integer", start: "a", delta: 1, err: ErrNotInteger},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHash()
+			if tt.start != nil {
+				h.Set("field", tt.start)
+			}
+
+			n, err := h.IncrBy("field", tt.delta)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				// A failed increment leaves the field as it was.
+				if val, _ := h.Get("field"); val != tt.start {
+					t.Errorf("got %v after a failed increment, want %v", val, tt.start)
+				}
+				return
+			}
+
+			if n != tt.n {
+				t.Errorf("got %d, want %d", n, tt.n)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	// hllPrecision is the number of hash bits used to pick a register.
+	hllPrecision = 14
+
+	// HLLRegisters is the number of registers of a HyperLogLog, the standard error is 1.04/sqrt(HLLRegisters).
+	HLLRegisters = 1 << hllPrecision
+)
+
+// ErrInvalidRegisters is raised when registers of another HyperLogLog have a different size.
+var ErrInvalidRegisters = errors.New("invalid hyperloglog registers")
+
+// HyperLogLog estimates the number of unique elements added to it using a fixed amount of memory.
+// Elements are hashed the same way on every node, so registers of HyperLogLogs on different nodes can be merged.
+type HyperLogLog struct {
+	registers []byte
+}
+
+// Verifying interface compliance.
+var _ Value = (*HyperLogLog)(nil)
+
+// NewHyperLogLog returns a new empty HyperLogLog.
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{registers: make([]byte, HLLRegisters)}
+}
+
+// Kind returns the data type of the value.
+func (h *HyperLogLog) Kind() Kind {
+	return KindHyperLogLog
+}
+
+// Size returns the memory used by the registers in bytes.
+func (h *HyperLogLog) Size() uint64 {
+	return HLLRegisters
+}
+
+// Add adds the elements and reports whether any register changed.
+func (h *HyperLogLog) Add(elements ...string) bool {
+	var changed bool
+
+	for _, element := range elements {
+		hash := hash64(element)
+
+		index := hash >> (64 - hllPrecision)
+		rank := byte(bits.LeadingZeros64(hash<<hllPrecision|1<<(hllPrecision-1)) + 1)
+
+		if rank > h.registers[index] {
+			h.registers[index] = rank
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// Count returns the estimated number of unique elements.
+func (h *HyperLogLog) Count() uint64 {
+	return EstimateRegisters(h.registers)
+}
+
+// Registers returns a copy of the registers.
+func (h *HyperLogLog) Registers() []byte {
+	registers := make([]byte, HLLRegisters)
+	copy(registers, h.registers)
+	return registers
+}
+
+// Merge merges registers of another HyperLogLog into this one.
+func (h *HyperLogLog) Merge(registers []byte) error {
+	return MergeRegisters(h.registers, registers)
+}
+
+// MergeRegisters merges src registers into dst, so dst estimates the union of both.
+func MergeRegisters(dst, src []byte) error {
+	if len(dst) != HLLRegisters || len(src) != HLLRegisters {
+		return ErrInvalidRegisters
+	}
+
+	for i, rank := range src {
+		if rank > dst[i] {
+			dst[i] = rank
+		}
+	}
+
+	return nil
+}
+
+// EstimateRegisters returns the estimated number of unique elements of the registers.
+func EstimateRegisters(registers []byte) uint64 {
+	m := float64(len(registers))
+	if m == 0 {
+		return 0
+	}
+
+	var sum float64
+	var zeros int
+	for _, rank := range registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Linear counting is more accurate for small cardinalities.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+// hash64 hashes the element with FNV-1a and mixes the result, since FNV's high bits are poorly distributed.
+func hash64(element string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(element))
+
+	return mix64(h.Sum64())
+}
+
+// mix64 is the finalizer of MurmurHash3, it spreads every input bit over every output bit.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
@@ -0,0 +1,158 @@
+package cache
+
+// List is a list of JSON values which can be pushed and popped at both ends in O(1).
+// It's backed by a ring buffer.
+type List struct {
+	elems []any
+	head  int
+	len   int
+	size  uint64
+}
+
+// Verifying interface compliance.
+var _ Value = (*List)(nil)
+
+// NewList returns a new empty list.
+func NewList() *List {
+	return &List{}
+}
+
+// Kind returns the data type of the value.
+func (l *List) Kind() Kind {
+	return KindList
+}
+
+// Size returns the approximate memory used by the list in bytes, it's the sum of its elements' sizes.
+func (l *List) Size() uint64 {
+	return l.size
+}
+
+// Len returns the number of elements.
+func (l *List) Len() int {
+	return l.len
+}
+
+// at returns the index of the i-th element in the ring buffer.
+func (l *List) at(i int) int {
+	return (l.head + i) % len(l.elems)
+}
+
+// grow makes room for at least another element.
+func (l *List) grow() {
+	if l.len < len(l.elems) {
+		return
+	}
+
+	elems := make([]any, 2*len(l.elems)+4)
+	for i := 0; i < l.len; i++ {
+		elems[i] = l.elems[l.at(i)]
+	}
+
+	l.elems = elems
+	l.head = 0
+}
+
+// PushFront pushes the values to the front of the list one by one, so the last value ends up first.
+func (l *List) PushFront(vals ...any) {
+	for _, val := range vals {
+		l.grow()
+		l.head = (l.head - 1 + len(l.elems)) % len(l.elems)
+		l.elems[l.head] = val
+		l.len++
+		l.size += sizeOf(val)
+	}
+}
+
+// PushBack pushes the values to the back of the list.
+func (l *List) PushBack(vals ...any) {
+	for _, val := range vals {
+		l.grow()
+		l.elems[l.at(l.len)] = val
+		l.len++
+		l.size += sizeOf(val)
+	}
+}
+
+// PopFront pops at most count values from the front of the list.
+func (l *List) PopFront(count int) []any {
+	if count > l.len {
+		count = l.len
+	}
+
+	vals := make([]any, 0, count)
+	for i := 0; i < count; i++ {
+		val := l.elems[l.head]
+		l.elems[l.head] = nil
+		l.head = l.at(1)
+		l.len--
+		l.size -= sizeOf(val)
+		vals = append(vals, val)
+	}
+
+	return vals
+}
+
+// PopBack pops at most count values from the back of the list.
+func (l *List) PopBack(count int) []any {
+	if count > l.len {
+		count = l.len
+	}
+
+	vals := make([]any, 0, count)
+	for i := 0; i < count; i++ {
+		j := l.at(l.len - 1)
+		val := l.elems[j]
+		l.elems[j] = nil
+		l.len--
+		l.size -= sizeOf(val)
+		vals = append(vals, val)
+	}
+
+	return vals
+}
+
+// bounds converts the inclusive start and stop indexes, which can be negative to count from the end,
+// to a half-open range of valid indexes.
+func (l *List) bounds(start, stop int) (int, int) {
+	if start < 0 {
+		start += l.len
+	}
+	if stop < 0 {
+		stop += l.len
+	}
+
+	if start < 0 {
+		start = 0
+	}
+	if stop >= l.len {
+		stop = l.len - 1
+	}
+
+	if start > stop {
+		return 0, 0
+	}
+
+	return start, stop + 1
+}
+
+// Range returns the elements between start and stop, both inclusive.
+// Negative indexes count from the end, -1 is the last element.
+func (l *List) Range(start, stop int) []any {
+	from, to := l.bounds(start, stop)
+
+	vals := make([]any, 0, to-from)
+	for i := from; i < to; i++ {
+		vals = append(vals, l.elems[l.at(i)])
+	}
+
+	return vals
+}
+
+// Trim keeps only the elements between start and stop, both inclusive.
+// Negative indexes count from the end, -1 is the last element.
+func (l *List) Trim(start, stop int) {
+	from, to := l.bounds(start, stop)
+
+	l.PopBack(l.len - to)
+	l.PopFront(from)
+}
@@ -1,61 +1,67 @@
 package list
 
 type (
-	// Node is the linked list's node.
-	Node struct {
-		key   string
-		value any
-		next  *Node
-		prev  *Node
+	// Node is the linked list's node holding a key of type K and a value of type V.
+	Node[K comparable, V any] struct {
+		key   K
+		value V
+		next  *Node[K, V]
+		prev  *Node[K, V]
 	}
 
 	// DoublyLinkedList is a doubly linked list of nodes.
-	DoublyLinkedList struct {
+	// It's the eviction order of caches, which keep their nodes by key.
+	DoublyLinkedList[K comparable, V any] struct {
 		size uint64
-		head *Node
-		tail *Node
+		head *Node[K, V]
+		tail *Node[K, V]
 	}
 )
 
 // NewDoublyLinkedList returns a new doubly linked list.
-func NewDoublyLinkedList() *DoublyLinkedList {
-	return &DoublyLinkedList{}
+func NewDoublyLinkedList[K comparable, V any]() *DoublyLinkedList[K, V] {
+	return &DoublyLinkedList[K, V]{}
 }
 
 // GetVal returns the node's value.
-func (n *Node) GetVal() any {
+func (n *Node[K, V]) GetVal() V {
 	return n.value
 }
 
 // SetVal sets node's value.
-func (n *Node) SetVal(val any) {
+func (n *Node[K, V]) SetVal(val V) {
 	n.value = val
 }
 
 // GetKey returns the node's key.
-func (n *Node) GetKey() string {
+func (n *Node[K, V]) GetKey() K {
 	return n.key
 }
 
+// Next returns the node after the node, nil if it's the tail.
+func (n *Node[K, V]) Next() *Node[K, V] {
+	return n.next
+}
+
 // Size returns the size of the linked list.
-func (l *DoublyLinkedList) Size() uint64 {
+func (l *DoublyLinkedList[K, V]) Size() uint64 {
 	return l.size
 }
 
 // Head returns the linked list's head.
-func (l *DoublyLinkedList) Head() *Node {
+func (l *DoublyLinkedList[K, V]) Head() *Node[K, V] {
 	return l.head
 }
 
 // Tail returns the linked list's tail.
-func (l *DoublyLinkedList) Tail() *Node {
+func (l *DoublyLinkedList[K, V]) Tail() *Node[K, V] {
 	return l.tail
 }
 
 // AddToBack adds a new key-value pair to the back of the linked list and returns the added node.
-func (l *DoublyLinkedList) AddToBack(key string, val any) *Node {
+func (l *DoublyLinkedList[K, V]) AddToBack(key K, val V) *Node[K, V] {
 	if l.size == 0 {
-		node := Node{key: key, value: val, next: nil, prev: nil}
+		node := Node[K, V]{key: key, value: val, next: nil, prev: nil}
 		l.head = &node
 		l.tail = &node
 		l.size++
@@ -63,7 +69,7 @@ func (l *DoublyLinkedList) AddToBack(key string, val any) *Node {
 		return &node
 	}
 
-	node := Node{key: key, value: val, next: nil, prev: l.tail}
+	node := Node[K, V]{key: key, value: val, next: nil, prev: l.tail}
 	l.tail.next = &node
 	l.tail = &node
 	l.size++
@@ -72,7 +78,7 @@ func (l *DoublyLinkedList) AddToBack(key string, val any) *Node {
 }
 
 // MoveToBack moves a node to the back of the linked list.
-func (l *DoublyLinkedList) MoveToBack(node *Node) {
+func (l *DoublyLinkedList[K, V]) MoveToBack(node *Node[K, V]) {
 	if l.size == 0 {
 		panic("list is empty")
 	}
@@ -93,12 +99,36 @@ func (l *DoublyLinkedList) MoveToBack(node *Node) {
 		next.prev = prev
 		node.next = nil
 		node.prev = l.tail
+		l.tail.next = node
 		l.tail = node
 	}
 }
 
+// Remove removes a node from the linked list.
+func (l *DoublyLinkedList[K, V]) Remove(node *Node[K, V]) {
+	if l.size == 0 {
+		panic("list is empty")
+	}
+
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		l.head = node.next
+	}
+
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		l.tail = node.prev
+	}
+
+	node.next = nil
+	node.prev = nil
+	l.size--
+}
+
 // RemoveHead removes the head node.
-func (l *DoublyLinkedList) RemoveHead() string {
+func (l *DoublyLinkedList[K, V]) RemoveHead() K {
 	if l.size == 0 {
 		panic("list is empty")
 	}
@@ -110,6 +140,8 @@ func (l *DoublyLinkedList) RemoveHead() string {
 	l.size--
 	if next != nil {
 		next.prev = nil
+	} else {
+		l.tail = nil
 	}
 
 	key := head.key
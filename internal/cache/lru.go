@@ -3,21 +3,45 @@ package cache
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/mojixcoder/caster/internal/app"
 	"github.com/mojixcoder/caster/internal/cache/list"
+	"github.com/mojixcoder/caster/internal/hlc"
+)
+
+const (
+	defaultExpiryInterval = time.Second
+
+	// expirySample is the number of keys which a round of the expiry sweep checks.
+	expirySample = 20
 )
 
 // LRUCache is the LRU cache.
+// It evicts the least recently used keys when it has more than capacity keys
+// or its values use more than maxMemory bytes. Zero limits are ignored.
 type LRUCache struct {
-	mutex    *sync.Mutex
-	list     *list.DoublyLinkedList
-	storage  map[string]*list.Node
-	capacity uint64
+	mutex     *sync.Mutex
+	list      *list.DoublyLinkedList[string, Item]
+	storage   map[string]*list.Node[string, Item]
+	capacity  uint64
+	maxMemory uint64
+	used      uint64
+	version   uint64
+	listener  Listener
+	clock     func() hlc.Timestamp
+}
+
+// lruTx gives access to a locked LRU cache.
+type lruTx struct {
+	cache *LRUCache
 }
 
 // Verifying interface compliance.
-var _ Cache = (*LRUCache)(nil)
+var (
+	_ Cache = (*LRUCache)(nil)
+	_ Tx    = lruTx{}
+)
 
 // ErrNotFound is raised when the given key is not found.
 var ErrNotFound error = errors.New("not found")
@@ -25,47 +49,139 @@ var ErrNotFound error = errors.New("not found")
 // NewLRUCache returns a new LRU cache.
 func NewLRUCache() *LRUCache {
 	cache := LRUCache{
-		mutex:    new(sync.Mutex),
-		list:     list.NewDoublyLinkedList(),
-		storage:  make(map[string]*list.Node),
-		capacity: app.App.Config.Caster.Capacity,
+		mutex:     new(sync.Mutex),
+		list:      list.NewDoublyLinkedList[string, Item](),
+		storage:   make(map[string]*list.Node[string, Item]),
+		capacity:  app.App.Config.Caster.Capacity,
+		maxMemory: app.App.Config.Caster.MaxMemory,
+		listener:  func(Event) {},
 	}
 
 	return &cache
 }
 
-// Get fetches a key from cache.
-func (c LRUCache) Get(key string) (any, error) {
+// Get fetches a scalar key from cache.
+func (c *LRUCache) Get(key string) (any, error) {
+	item, err := c.GetItem(key)
+	if err != nil {
+		return nil, err
+	}
+
+	scalar, ok := item.Value.(Scalar)
+	if !ok {
+		return nil, ErrWrongType
+	}
+
+	return scalar.Get(), nil
+}
+
+// GetItem fetches a key from cache along with its metadata.
+// Only scalar values are safe to use after it returns, use View for other kinds.
+func (c *LRUCache) GetItem(key string) (Item, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if node, ok := c.storage[key]; !ok {
-		return nil, ErrNotFound
-	} else {
-		c.list.MoveToBack(node)
-		val := node.GetVal()
-		return val, nil
+	node, ok := c.lookup(key)
+	if !ok {
+		return Item{}, ErrNotFound
+	}
+
+	c.list.MoveToBack(node)
+	return node.GetVal(), nil
+}
+
+// Set sets or overwrites the scalar key-value to cache.
+func (c *LRUCache) Set(key string, val any) error {
+	return c.SetItem(key, Item{Value: NewScalar(val)})
+}
+
+// SetItem sets or overwrites the key and its metadata to cache.
+func (c *LRUCache) SetItem(key string, item Item) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.fits(item.Value) {
+		return ErrTooLarge
 	}
+
+	c.store(key, item)
+
+	return nil
 }
 
-// Set sets or overwrites the key-value to cache.
-func (c LRUCache) Set(key string, val any) error {
+// Update atomically updates the value of a key. Expiry of existing keys is kept, the timestamp is replaced by a new one.
+func (c *LRUCache) Update(key string, fn UpdateFunc) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if node, ok := c.storage[key]; ok {
-		node.SetVal(val)
-		c.list.MoveToBack(node)
-	} else {
-		if c.capacity == c.list.Size() {
-			key := c.list.RemoveHead()
-			delete(c.storage, key)
+	var item Item
+	node, ok := c.lookup(key)
+	if ok {
+		item = node.GetVal()
+	}
+
+	val, err := fn(item.Value)
+	if err != nil {
+		return err
+	}
+
+	if val == nil {
+		if ok {
+			c.remove(node, OpDelete)
 		}
+		return nil
+	}
 
-		node := c.list.AddToBack(key, val)
-		c.storage[key] = node
+	if !c.fits(val) {
+		// The value may have been modified in place, so the old value can't be kept either.
+		if ok {
+			c.remove(node, OpEvicted)
+		}
+		return ErrTooLarge
+	}
+
+	// The update is a new write, so it's timestamped again.
+	item.Value = val
+	item.Timestamp = 0
+	c.store(key, item)
+
+	return nil
+}
+
+// View atomically reads the value of a key.
+func (c *LRUCache) View(key string, fn ViewFunc) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	node, ok := c.lookup(key)
+	if !ok {
+		return ErrNotFound
+	}
+
+	c.list.MoveToBack(node)
+	return fn(node.GetVal().Value)
+}
+
+// Atomic runs fn while the cache is locked.
+func (c *LRUCache) Atomic(fn func(tx Tx) error) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return fn(lruTx{cache: c})
+}
+
+// Delete deletes the key from cache.
+func (c *LRUCache) Delete(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	node, ok := c.storage[key]
+	if !ok {
+		return ErrNotFound
 	}
 
+	c.remove(node, OpDelete)
+
 	return nil
 }
 
@@ -74,8 +190,205 @@ func (c *LRUCache) Flush() error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.storage = make(map[string]*list.Node)
-	c.list = list.NewDoublyLinkedList()
+	c.storage = make(map[string]*list.Node[string, Item])
+	c.list = list.NewDoublyLinkedList[string, Item]()
+	c.used = 0
+
+	c.version++
+	c.listener(Event{Op: OpFlush, Version: c.version})
 
 	return nil
 }
+
+// StartExpiry starts sweeping expired keys in the background every configured interval,
+// so keys which aren't accessed anymore are removed and their expired events are sent.
+// Like Redis, a round checks a sample of keys and another round follows while more than a quarter of them are expired.
+func (c *LRUCache) StartExpiry() {
+	interval := app.App.Config.Caster.ExpiryInterval
+	if interval <= 0 {
+		interval = defaultExpiryInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			// A sweep takes a quarter of the interval at most, so it doesn't hold up the cache for long.
+			deadline := time.Now().Add(interval / 4)
+			for {
+				if expired := c.expireSample(expirySample); expired <= expirySample/4 || time.Now().After(deadline) {
+					break
+				}
+			}
+		}
+	}()
+}
+
+// expireSample removes the expired keys among n keys and returns how many it removed.
+// The keys are picked by map iteration, whose order is random.
+func (c *LRUCache) expireSample(n int) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	var checked, expired int
+	for _, node := range c.storage {
+		if checked == n {
+			break
+		}
+		checked++
+
+		if node.GetVal().IsExpired(now) {
+			c.remove(node, OpExpired)
+			expired++
+		}
+	}
+
+	return expired
+}
+
+// SetListener sets the listener which is notified about every change in the cache.
+func (c *LRUCache) SetListener(listener Listener) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.listener = listener
+}
+
+// SetClock sets the clock which timestamps the writes without a timestamp.
+func (c *LRUCache) SetClock(clock func() hlc.Timestamp) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.clock = clock
+}
+
+// lookup returns the node of a key. Expired keys are removed and reported as not found.
+// It must be called while the cache is locked.
+func (c *LRUCache) lookup(key string) (*list.Node[string, Item], bool) {
+	node, ok := c.storage[key]
+	if !ok {
+		return nil, false
+	}
+
+	if node.GetVal().IsExpired(time.Now()) {
+		c.remove(node, OpExpired)
+		return nil, false
+	}
+
+	return node, true
+}
+
+// store sets the item of a key, evicts other keys if needed and notifies the listener.
+// Items without a timestamp are timestamped by the clock, if there is one.
+// It must be called while the cache is locked.
+func (c *LRUCache) store(key string, item Item) {
+	c.version++
+	item.Version = c.version
+	if item.Timestamp == 0 && c.clock != nil {
+		item.Timestamp = c.clock()
+	}
+	item.size = item.Value.Size()
+
+	node, ok := c.storage[key]
+	if ok {
+		c.used -= node.GetVal().size
+		node.SetVal(item)
+		c.list.MoveToBack(node)
+	} else {
+		node = c.list.AddToBack(key, item)
+		c.storage[key] = node
+	}
+	c.used += item.size
+
+	// The stored key is at the back of the list, so it's evicted last.
+	for c.list.Head() != node && c.isFull() {
+		c.remove(c.list.Head(), OpEvicted)
+	}
+
+	c.listener(Event{Key: key, Op: OpSet, Version: item.Version})
+}
+
+// fits determines if the value fits in the cache's memory.
+func (c *LRUCache) fits(val Value) bool {
+	return c.maxMemory == 0 || val.Size() <= c.maxMemory
+}
+
+// isFull determines if the cache has exceeded its limits.
+func (c *LRUCache) isFull() bool {
+	return (c.capacity > 0 && c.list.Size() > c.capacity) || (c.maxMemory > 0 && c.used > c.maxMemory)
+}
+
+// remove removes the node from cache and notifies the listener.
+// It must be called while the cache is locked.
+func (c *LRUCache) remove(node *list.Node[string, Item], op Op) {
+	key := node.GetKey()
+	item := node.GetVal()
+
+	c.list.Remove(node)
+	delete(c.storage, key)
+	c.used -= item.size
+
+	c.listener(Event{Key: key, Op: op, Version: item.Version})
+}
+
+// Get returns the value of a key, nil if it doesn't exist.
+func (tx lruTx) Get(key string) Value {
+	node, ok := tx.cache.lookup(key)
+	if !ok {
+		return nil
+	}
+
+	tx.cache.list.MoveToBack(node)
+	return node.GetVal().Value
+}
+
+// GetItem returns the item of a key and whether it exists.
+func (tx lruTx) GetItem(key string) (Item, bool) {
+	node, ok := tx.cache.lookup(key)
+	if !ok {
+		return Item{}, false
+	}
+
+	tx.cache.list.MoveToBack(node)
+	return node.GetVal(), true
+}
+
+// Fits determines if the values fit in the cache together, by their number and their memory.
+func (tx lruTx) Fits(vals ...Value) bool {
+	if tx.cache.capacity > 0 && uint64(len(vals)) > tx.cache.capacity {
+		return false
+	}
+
+	var size uint64
+	for _, val := range vals {
+		size += val.Size()
+	}
+
+	return tx.cache.maxMemory == 0 || size <= tx.cache.maxMemory
+}
+
+// SetItem sets the item of a key.
+func (tx lruTx) SetItem(key string, item Item) error {
+	if !tx.cache.fits(item.Value) {
+		return ErrTooLarge
+	}
+
+	tx.cache.store(key, item)
+
+	return nil
+}
+
+// Delete deletes a key and reports whether it existed.
+func (tx lruTx) Delete(key string) bool {
+	node, ok := tx.cache.lookup(key)
+	if !ok {
+		return false
+	}
+
+	tx.cache.remove(node, OpDelete)
+
+	return true
+}
@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/config"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	app.App = &app.AppRepo{Logger: zap.NewNop(), Config: &config.AppConfig{Caster: &config.CasterConfig{}}}
+	os.Exit(m.Run())
+}
+
+// newTestLRUCache returns an LRU cache with the given limits.
+func newTestLRUCache(capacity, maxMemory uint64) *LRUCache {
+	c := NewLRUCache()
+	c.capacity = capacity
+	c.maxMemory = maxMemory
+	return c
+}
+
+func TestLRUTxFits(t *testing.T) {
+	val := NewScalar("value")
+
+	tests := []struct {
+		name      string
+		capacity  uint64
+		maxMemory uint64
+		vals      []Value
+		fits      bool
+	}{
+		{name: "no limits", vals: []Value{val, val, val}, fits: true},
+		{name: "no values", capacity: 1, maxMemory: 1, fits: true},
+		{name: "within capacity", capacity: 2, vals: []Value{val, val}, fits: true},
+		{name: "above capacity", capacity: 2, vals: []Value{val, val, val}},
+		{name: "within memory", maxMemory: 2 * val.Size(), vals: []Value{val, val}, fits: true},
+		{name: "above memory", maxMemory: 2*val.Size() - 1, vals: []Value{val, val}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestLRUCache(tt.capacity, tt.maxMemory)
+			c.Atomic(func(tx Tx) error {
+				if fits := tx.Fits(tt.vals...); fits != tt.fits {
+					t.Errorf("got %t, want %t", fits, tt.fits)
+				}
+				return nil
+			})
+		})
+	}
+}
+
+func TestLRUTxEvictsOtherKeysFirst(t *testing.T) {
+	c := newTestLRUCache(3, 0)
+	for i := 0; i < 3; i++ {
+		c.Set(fmt.Sprintf("old%d", i), i)
+	}
+
+	keys := []string{"new0", "new1", "new2"}
+	err := c.Atomic(func(tx Tx) error {
+		vals := []Value{NewScalar(0), NewScalar(1), NewScalar(2)}
+		if !tx.Fits(vals...) {
+			t.Fatal("values of the transaction don't fit")
+		}
+
+		for i, key := range keys {
+			if err := tx.SetItem(key, Item{Value: vals[i]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got %v", err)
+	}
+
+	for i, key := range keys {
+		if val, err := c.Get(key); err != nil || val != i {
+			t.Errorf("got %v %v for %s, want %d", val, err, key, i)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(fmt.Sprintf("old%d", i)); err != ErrNotFound {
+			t.Errorf("got %v for old%d, want it evicted", err, i)
+		}
+	}
+}
+
+func TestLRUExpireSample(t *testing.T) {
+	c := newTestLRUCache(0, 0)
+
+	var expired []string
+	c.SetListener(func(e Event) {
+		if e.Op == OpExpired {
+			expired = append(expired, e.Key)
+		}
+	})
+
+	past := time.Now().Add(-time.Second)
+	for i := 0; i < 10; i++ {
+		item := Item{Value: NewScalar(i)}
+		if i%2 == 0 {
+			item.HardExpiry = past
+		}
+		c.SetItem(fmt.Sprintf("key%d", i), item)
+	}
+
+	// Expired keys are removed without being accessed, live ones are kept.
+	if n := c.expireSample(100); n != 5 {
+		t.Errorf("got %d expired keys, want 5", n)
+	}
+	if len(expired) != 5 {
+		t.Errorf("got %d expired events, want 5", len(expired))
+	}
+	if size := c.list.Size(); size != 5 {
+		t.Errorf("got %d keys, want 5", size)
+	}
+
+	// A sample only checks n keys.
+	for i := 0; i < 10; i++ {
+		c.SetItem(fmt.Sprintf("old%d", i), Item{Value: NewScalar(i), HardExpiry: past})
+	}
+	if n := c.expireSample(3); n > 3 {
+		t.Errorf("got %d expired keys from a sample of 3", n)
+	}
+}
+
+func TestLRUClock(t *testing.T) {
+	c := newTestLRUCache(0, 0)
+
+	var now hlc.Timestamp
+	c.SetClock(func() hlc.Timestamp {
+		now++
+		return now
+	})
+
+	timestamp := func(key string) hlc.Timestamp {
+		item, err := c.GetItem(key)
+		if err != nil {
+			t.Fatalf("got %v for %s", err, key)
+		}
+		return item.Timestamp
+	}
+
+	c.Set("scalar", 1)
+	if ts := timestamp("scalar"); ts != 1 {
+		t.Errorf("got timestamp %s for a write without one, want 1", ts)
+	}
+
+	// Writes which have a timestamp keep it.
+	c.SetItem("stamped", Item{Value: NewScalar(1), Timestamp: 100})
+	if ts := timestamp("stamped"); ts != 100 {
+		t.Errorf("got timestamp %s, want 100", ts)
+	}
+
+	// Updates are new writes, even though the rest of the item is kept.
+	c.Update("stamped", func(val Value) (Value, error) {
+		return NewScalar(2), nil
+	})
+	if ts := timestamp("stamped"); ts != 2 {
+		t.Errorf("got timestamp %s after an update, want 2", ts)
+	}
+}
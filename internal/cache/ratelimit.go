@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// rateLimiterSize is the approximate memory used by a rate limiter in bytes.
+const rateLimiterSize = 48
+
+// ErrInvalidRateLimit is raised when a rate limiter is used with invalid parameters.
+var ErrInvalidRateLimit = errors.New("limit and window must be positive and cost must be between 0 and limit")
+
+type (
+	// RateLimiter limits the cost which can be spent in a window of time.
+	RateLimiter interface {
+		Value
+
+		// Take spends cost if it's allowed at the given time.
+		// It returns the remaining cost that can be spent right away and,
+		// if it's not allowed, how long to wait before it's allowed.
+		Take(now time.Time, limit int64, window time.Duration, cost int64) RateLimitResult
+	}
+
+	// RateLimitResult is the result of taking from a rate limiter.
+	RateLimitResult struct {
+		Allowed    bool
+		Remaining  int64
+		RetryAfter time.Duration
+	}
+
+	// TokenBucket is a rate limiter which refills limit tokens evenly during every window, up to limit.
+	// It allows bursts of up to limit.
+	TokenBucket struct {
+		tokens float64
+		last   time.Time
+	}
+
+	// SlidingWindow is a rate limiter which allows limit in any window.
+	// It approximates the cost spent in the last window by weighting the previous fixed window's cost.
+	SlidingWindow struct {
+		start    time.Time
+		previous int64
+		current  int64
+	}
+)
+
+// Verifying interface compliance.
+var (
+	_ RateLimiter = (*TokenBucket)(nil)
+	_ RateLimiter = (*SlidingWindow)(nil)
+)
+
+// ValidateRateLimit validates the parameters of a rate limiter.
+func ValidateRateLimit(limit int64, window time.Duration, cost int64) error {
+	if limit <= 0 || window <= 0 || cost < 0 || cost > limit {
+		return ErrInvalidRateLimit
+	}
+	return nil
+}
+
+// NewTokenBucket returns a new token bucket, it's full at the first take.
+func NewTokenBucket() *TokenBucket {
+	return &TokenBucket{tokens: -1}
+}
+
+// Kind returns the data type of the value.
+func (b *TokenBucket) Kind() Kind {
+	return KindTokenBucket
+}
+
+// Size returns the approximate memory used by the token bucket in bytes.
+func (b *TokenBucket) Size() uint64 {
+	return rateLimiterSize
+}
+
+// Take spends cost tokens if there are enough of them.
+func (b *TokenBucket) Take(now time.Time, limit int64, window time.Duration, cost int64) RateLimitResult {
+	rate := float64(limit) / float64(window)
+
+	if b.tokens < 0 {
+		b.tokens = float64(limit)
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(limit), b.tokens+float64(elapsed)*rate)
+	}
+	// The limit may have been lowered since the last take.
+	b.tokens = math.Min(float64(limit), b.tokens)
+	b.last = now
+
+	var res RateLimitResult
+	if b.tokens >= float64(cost) {
+		b.tokens -= float64(cost)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((float64(cost) - b.tokens) / rate))
+	}
+	res.Remaining = int64(b.tokens)
+
+	return res
+}
+
+// NewSlidingWindow returns a new sliding window.
+func NewSlidingWindow() *SlidingWindow {
+	return &SlidingWindow{}
+}
+
+// Kind returns the data type of the value.
+func (w *SlidingWindow) Kind() Kind {
+	return KindSlidingWindow
+}
+
+// Size returns the approximate memory used by the sliding window in bytes.
+func (w *SlidingWindow) Size() uint64 {
+	return rateLimiterSize
+}
+
+// Take spends cost if the cost spent in the last window allows it.
+func (w *SlidingWindow) Take(now time.Time, limit int64, window time.Duration, cost int64) RateLimitResult {
+	w.slide(now, window)
+
+	elapsed := now.Sub(w.start)
+	weight := 1 - float64(elapsed)/float64(window)
+	spent := int64(math.Ceil(float64(w.previous)*weight)) + w.current
+
+	var res RateLimitResult
+	if spent+cost <= limit {
+		w.current += cost
+		spent += cost
+		res.Allowed = true
+	} else {
+		res.RetryAfter = w.retryAfter(elapsed, limit, window, cost)
+	}
+	res.Remaining = limit - spent
+	if res.Remaining < 0 {
+		res.Remaining = 0
+	}
+
+	return res
+}
+
+// slide moves the fixed windows forward so the current one contains now.
+func (w *SlidingWindow) slide(now time.Time, window time.Duration) {
+	if w.start.IsZero() {
+		w.start = now.Truncate(window)
+		return
+	}
+
+	switch windows := now.Sub(w.start) / window; {
+	case windows == 1:
+		w.previous, w.current = w.current, 0
+	case windows > 1:
+		w.previous, w.current = 0, 0
+	default:
+		return
+	}
+	w.start = now.Truncate(window)
+}
+
+// retryAfter returns how long it takes for the previous window's weight to drop enough for cost to be allowed.
+func (w *SlidingWindow) retryAfter(elapsed time.Duration, limit int64, window time.Duration, cost int64) time.Duration {
+	// Waiting within the current window.
+	if free := limit - cost - w.current; free >= 0 && w.previous > 0 {
+		wait := time.Duration((1-float64(free)/float64(w.previous))*float64(window)) - elapsed
+		if wait < 0 {
+			wait = 0
+		}
+		if wait < window-elapsed {
+			return wait
+		}
+	}
+
+	// Waiting for the next window, where the current window becomes the previous one.
+	wait := window - elapsed
+	if w.current > 0 {
+		wait += time.Duration(math.Max(0, (1-float64(limit-cost)/float64(w.current))*float64(window)))
+	}
+	return wait
+}
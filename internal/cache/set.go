@@ -0,0 +1,152 @@
+package cache
+
+import "sort"
+
+// Set is an unordered set of unique string members.
+type Set struct {
+	members map[string]struct{}
+	size    uint64
+}
+
+// Verifying interface compliance.
+var _ Value = (*Set)(nil)
+
+// NewSet returns a new empty set.
+func NewSet() *Set {
+	return &Set{members: make(map[string]struct{})}
+}
+
+// Kind returns the data type of the value.
+func (s *Set) Kind() Kind {
+	return KindSet
+}
+
+// Size returns the approximate memory used by the set in bytes, it's the sum of its members' sizes.
+func (s *Set) Size() uint64 {
+	return s.size
+}
+
+// Len returns the number of members.
+func (s *Set) Len() int {
+	return len(s.members)
+}
+
+// Has determines if the member is in the set.
+func (s *Set) Has(member string) bool {
+	_, ok := s.members[member]
+	return ok
+}
+
+// Add adds the members and returns the number of new ones.
+func (s *Set) Add(members ...string) int {
+	var added int
+	for _, member := range members {
+		if s.Has(member) {
+			continue
+		}
+
+		s.members[member] = struct{}{}
+		s.size += uint64(len(member))
+		added++
+	}
+	return added
+}
+
+// Remove removes the members and returns the number of removed ones.
+func (s *Set) Remove(members ...string) int {
+	var removed int
+	for _, member := range members {
+		if !s.Has(member) {
+			continue
+		}
+
+		delete(s.members, member)
+		s.size -= uint64(len(member))
+		removed++
+	}
+	return removed
+}
+
+// Members returns the members in sorted order.
+func (s *Set) Members() []string {
+	members := make([]string, 0, len(s.members))
+	for member := range s.members {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// Intersect returns the members which are in every set. Nil sets are treated as empty.
+func Intersect(sets ...*Set) []string {
+	members := make([]string, 0)
+	if len(sets) == 0 {
+		return members
+	}
+
+	for _, set := range sets {
+		if set == nil {
+			return members
+		}
+	}
+
+	// Iterating the smallest set is enough.
+	smallest := sets[0]
+	for _, set := range sets[1:] {
+		if set.Len() < smallest.Len() {
+			smallest = set
+		}
+	}
+
+	for _, member := range smallest.Members() {
+		inAll := true
+		for _, set := range sets {
+			if !set.Has(member) {
+				inAll = false
+				break
+			}
+		}
+
+		if inAll {
+			members = append(members, member)
+		}
+	}
+
+	return members
+}
+
+// Union returns the members which are in at least a set. Nil sets are treated as empty.
+func Union(sets ...*Set) []string {
+	union := NewSet()
+	for _, set := range sets {
+		if set == nil {
+			continue
+		}
+		union.Add(set.Members()...)
+	}
+	return union.Members()
+}
+
+// Diff returns the members of the first set which aren't in the other ones. Nil sets are treated as empty.
+func Diff(sets ...*Set) []string {
+	members := make([]string, 0)
+	if len(sets) == 0 || sets[0] == nil {
+		return members
+	}
+
+	for _, member := range sets[0].Members() {
+		var found bool
+		for _, set := range sets[1:] {
+			if set != nil && set.Has(member) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			members = append(members, member)
+		}
+	}
+
+	return members
+}
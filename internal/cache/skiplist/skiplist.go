@@ -0,0 +1,244 @@
+package skiplist
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	// maxLevel is the maximum number of levels, it's enough for 4^32 elements.
+	maxLevel = 32
+
+	// probability is the probability of an element having another level.
+	probability = 0.25
+)
+
+type (
+	// level is a level of an element.
+	level struct {
+		forward *Element
+
+		// span is the number of elements between the element and forward.
+		span int
+	}
+
+	// Element is an element of the skip list.
+	Element struct {
+		Member string
+		Score  float64
+
+		backward *Element
+		levels   []level
+	}
+
+	// SkipList is a skip list of members ordered by score, then by member.
+	// Every level keeps its spans, so elements can be found by rank in O(log(n)).
+	SkipList struct {
+		header *Element
+		tail   *Element
+		length int
+		level  int
+		rand   *rand.Rand
+	}
+)
+
+// New returns a new skip list.
+func New() *SkipList {
+	return &SkipList{
+		header: &Element{levels: make([]level, maxLevel)},
+		level:  1,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Next returns the next element or nil.
+func (e *Element) Next() *Element {
+	return e.levels[0].forward
+}
+
+// Prev returns the previous element or nil.
+func (e *Element) Prev() *Element {
+	return e.backward
+}
+
+// before determines if the element is ordered before the given score and member.
+func (e *Element) before(score float64, member string) bool {
+	return e.Score < score || (e.Score == score && e.Member < member)
+}
+
+// Len returns the number of elements.
+func (s *SkipList) Len() int {
+	return s.length
+}
+
+// First returns the first element or nil.
+func (s *SkipList) First() *Element {
+	return s.header.levels[0].forward
+}
+
+// Last returns the last element or nil.
+func (s *SkipList) Last() *Element {
+	return s.tail
+}
+
+// randomLevel returns a random level for a new element.
+func (s *SkipList) randomLevel() int {
+	lvl := 1
+	for lvl < maxLevel && s.rand.Float64() < probability {
+		lvl++
+	}
+	return lvl
+}
+
+// Insert inserts a new element. The member must not already be in the skip list.
+func (s *SkipList) Insert(member string, score float64) *Element {
+	var update [maxLevel]*Element
+	var rank [maxLevel]int
+
+	x := s.header
+	for i := s.level - 1; i >= 0; i-- {
+		if i != s.level-1 {
+			rank[i] = rank[i+1]
+		}
+
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	lvl := s.randomLevel()
+	if lvl > s.level {
+		for i := s.level; i < lvl; i++ {
+			rank[i] = 0
+			update[i] = s.header
+			update[i].levels[i].span = s.length
+		}
+		s.level = lvl
+	}
+
+	x = &Element{Member: member, Score: score, levels: make([]level, lvl)}
+	for i := 0; i < lvl; i++ {
+		x.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = x
+
+		x.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+
+	for i := lvl; i < s.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != s.header {
+		x.backward = update[0]
+	}
+
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x
+	} else {
+		s.tail = x
+	}
+
+	s.length++
+
+	return x
+}
+
+// Delete deletes the element with the given member and score and reports whether it existed.
+func (s *SkipList) Delete(member string, score float64) bool {
+	var update [maxLevel]*Element
+
+	x := s.header
+	for i := s.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.levels[0].forward
+	if x == nil || x.Score != score || x.Member != member {
+		return false
+	}
+
+	for i := 0; i < s.level; i++ {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x.backward
+	} else {
+		s.tail = x.backward
+	}
+
+	for s.level > 1 && s.header.levels[s.level-1].forward == nil {
+		s.level--
+	}
+
+	s.length--
+
+	return true
+}
+
+// Rank returns the 0-based rank of the element with the given member and score, or -1 if it doesn't exist.
+func (s *SkipList) Rank(member string, score float64) int {
+	var rank int
+
+	x := s.header
+	for i := s.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil &&
+			(x.levels[i].forward.before(score, member) || (x.levels[i].forward.Score == score && x.levels[i].forward.Member == member)) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+
+		if x != s.header && x.Member == member && x.Score == score {
+			return rank - 1
+		}
+	}
+
+	return -1
+}
+
+// ByRank returns the element with the given 0-based rank or nil.
+func (s *SkipList) ByRank(rank int) *Element {
+	if rank < 0 || rank >= s.length {
+		return nil
+	}
+
+	var traversed int
+	target := rank + 1
+
+	x := s.header
+	for i := s.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= target {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+
+		if traversed == target {
+			return x
+		}
+	}
+
+	return nil
+}
+
+// FirstFrom returns the first element with a score greater than or equal to min, or nil.
+func (s *SkipList) FirstFrom(min float64) *Element {
+	x := s.header
+	for i := s.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.Score < min {
+			x = x.levels[i].forward
+		}
+	}
+
+	return x.levels[0].forward
+}
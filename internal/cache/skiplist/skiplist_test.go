@@ -0,0 +1,179 @@
+package skiplist
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// member is a member of the reference model which the skip list is checked against.
+type member struct {
+	name  string
+	score float64
+}
+
+// sorted returns the members in the order of the skip list.
+func sorted(members map[string]float64) []member {
+	res := make([]member, 0, len(members))
+	for name, score := range members {
+		res = append(res, member{name: name, score: score})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].score < res[j].score || (res[i].score == res[j].score && res[i].name < res[j].name)
+	})
+
+	return res
+}
+
+// check verifies the skip list against the members in both directions, by rank and by member.
+func check(t *testing.T, s *SkipList, want []member) {
+	t.Helper()
+
+	if s.Len() != len(want) {
+		t.Fatalf("got length %d, want %d", s.Len(), len(want))
+	}
+
+	i := 0
+	for e := s.First(); e != nil; e = e.Next() {
+		if e.Member != want[i].name || e.Score != want[i].score {
+			t.Fatalf("got %s (%v) at %d, want %s (%v)", e.Member, e.Score, i, want[i].name, want[i].score)
+		}
+		i++
+	}
+
+	i = len(want) - 1
+	for e := s.Last(); e != nil; e = e.Prev() {
+		if e.Member != want[i].name {
+			t.Fatalf("got %s at %d going backward, want %s", e.Member, i, want[i].name)
+		}
+		i--
+	}
+	if i != -1 {
+		t.Fatalf("backward iteration stopped at %d", i)
+	}
+
+	for rank, m := range want {
+		if got := s.Rank(m.name, m.score); got != rank {
+			t.Fatalf("got rank %d of %s, want %d", got, m.name, rank)
+		}
+
+		if e := s.ByRank(rank); e == nil || e.Member != m.name {
+			t.Fatalf("got %v by rank %d, want %s", e, rank, m.name)
+		}
+	}
+}
+
+func TestSkipList(t *testing.T) {
+	s := New()
+	s.Insert("c", 2)
+	s.Insert("a", 1)
+	s.Insert("b", 2)
+	s.Insert("d", -1)
+
+	want := []member{{"d", -1}, {"a", 1}, {"b", 2}, {"c", 2}}
+	check(t, s, want)
+
+	tests := []struct {
+		name     string
+		member   string
+		score    float64
+		rank     int
+		deleted  bool
+		remained []member
+	}{
+		{name: "missing member", member: "x", score: 1, rank: -1, remained: want},
+		{name: "wrong score", member: "a", score: 2, rank: -1, remained: want},
+		{name: "middle", member: "b", score: 2, rank: 2, deleted: true, remained: []member{{"d", -1}, {"a", 1}, {"c", 2}}},
+		{name: "first", member: "d", score: -1, rank: 0, deleted: true, remained: []member{{"a", 1}, {"c", 2}}},
+		{name: "last", member: "c", score: 2, rank: 1, deleted: true, remained: []member{{"a", 1}}},
+		{name: "only", member: "a", score: 1, rank: 0, deleted: true, remained: []member{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rank := s.Rank(tt.member, tt.score); rank != tt.rank {
+				t.Errorf("got rank %d, want %d", rank, tt.rank)
+			}
+
+			if deleted := s.Delete(tt.member, tt.score); deleted != tt.deleted {
+				t.Errorf("got deleted %t, want %t", deleted, tt.deleted)
+			}
+
+			check(t, s, tt.remained)
+		})
+	}
+
+	if s.First() != nil || s.Last() != nil || s.ByRank(0) != nil {
+		t.Error("empty skip list has elements")
+	}
+}
+
+func TestSkipListRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	s := New()
+	members := make(map[string]float64)
+
+	for i := 0; i < 5000; i++ {
+		name := fmt.Sprintf("m%d", rnd.Intn(1000))
+
+		// Few distinct scores, so many members are ordered by name.
+		score := float64(rnd.Intn(50) - 25)
+
+		if old, ok := members[name]; ok {
+			if !s.Delete(name, old) {
+				t.Fatalf("failed to delete %s (%v)", name, old)
+			}
+			delete(members, name)
+		}
+
+		if rnd.Intn(3) > 0 {
+			s.Insert(name, score)
+			members[name] = score
+		}
+	}
+
+	want := sorted(members)
+	check(t, s, want)
+
+	if s.ByRank(-1) != nil || s.ByRank(len(want)) != nil {
+		t.Error("got an element by an out of range rank")
+	}
+}
+
+func TestSkipListFirstFrom(t *testing.T) {
+	s := New()
+	for i, score := range []float64{-2, 0, 0, 1.5, 3} {
+		s.Insert(fmt.Sprintf("m%d", i), score)
+	}
+
+	tests := []struct {
+		min    float64
+		member string
+	}{
+		{min: -10, member: "m0"},
+		{min: -2, member: "m0"},
+		{min: -1, member: "m1"},
+		{min: 0, member: "m1"},
+		{min: 1, member: "m3"},
+		{min: 3, member: "m4"},
+		{min: 3.5, member: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.min), func(t *testing.T) {
+			e := s.FirstFrom(tt.min)
+			if tt.member == "" {
+				if e != nil {
+					t.Errorf("got %s, want nil", e.Member)
+				}
+				return
+			}
+
+			if e == nil || e.Member != tt.member {
+				t.Errorf("got %v, want %s", e, tt.member)
+			}
+		})
+	}
+}
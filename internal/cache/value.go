@@ -0,0 +1,119 @@
+package cache
+
+import "errors"
+
+// Kind is the data type of a value.
+type Kind string
+
+const (
+	// KindScalar is a plain JSON value.
+	KindScalar Kind = "scalar"
+
+	// KindHash is a map of fields to JSON values.
+	KindHash Kind = "hash"
+
+	// KindList is a list of JSON values.
+	KindList Kind = "list"
+
+	// KindZSet is a sorted set of members ordered by their scores.
+	KindZSet Kind = "zset"
+
+	// KindSet is an unordered set of unique members.
+	KindSet Kind = "set"
+
+	// KindHyperLogLog is a cardinality estimator.
+	KindHyperLogLog Kind = "hyperloglog"
+
+	// KindBloomFilter is a probabilistic membership filter.
+	KindBloomFilter Kind = "bloomfilter"
+
+	// KindTokenBucket is a token bucket rate limiter.
+	KindTokenBucket Kind = "tokenbucket"
+
+	// KindSlidingWindow is a sliding window rate limiter.
+	KindSlidingWindow Kind = "slidingwindow"
+)
+
+var (
+	// ErrWrongType is raised when an operation is performed against a key holding the wrong kind of value.
+	ErrWrongType = errors.New("operation against a key holding the wrong kind of value")
+
+	// ErrNotInteger is raised when an integer operation is performed against a value that's not an integer.
+	ErrNotInteger = errors.New("value is not an integer")
+
+	// ErrNotANumber is raised when an operation results in a value that's not a number.
+	ErrNotANumber = errors.New("resulting value is not a number")
+
+	// ErrOverflow is raised when an integer operation results in a value that doesn't fit in an int64.
+	ErrOverflow = errors.New("increment or decrement would overflow")
+
+	// ErrExists is raised when a key that's being created already exists.
+	ErrExists = errors.New("key already exists")
+
+	// ErrTooLarge is raised when a value doesn't fit in the cache's memory.
+	ErrTooLarge = errors.New("value is larger than the cache's max memory")
+
+	// ErrOutdated is raised when a write is older than the write which set the key.
+	ErrOutdated = errors.New("key has a newer write")
+)
+
+// Value is a typed value stored in the cache.
+type Value interface {
+	// Kind returns the data type of the value.
+	Kind() Kind
+
+	// Size returns the approximate memory used by the value in bytes.
+	Size() uint64
+}
+
+// Scalar is a plain JSON value.
+type Scalar struct {
+	val  any
+	size uint64
+}
+
+// Verifying interface compliance.
+var _ Value = Scalar{}
+
+// NewScalar returns a new scalar value.
+func NewScalar(val any) Scalar {
+	return Scalar{val: val, size: sizeOf(val)}
+}
+
+// Kind returns the data type of the value.
+func (s Scalar) Kind() Kind {
+	return KindScalar
+}
+
+// Size returns the approximate memory used by the value in bytes.
+func (s Scalar) Size() uint64 {
+	return s.size
+}
+
+// Get returns the underlying JSON value.
+func (s Scalar) Get() any {
+	return s.val
+}
+
+// sizeOf returns the approximate memory used by a JSON value in bytes.
+func sizeOf(val any) uint64 {
+	switch v := val.(type) {
+	case string:
+		return uint64(len(v))
+	case []any:
+		size := uint64(8 * len(v))
+		for _, e := range v {
+			size += sizeOf(e)
+		}
+		return size
+	case map[string]any:
+		var size uint64
+		for k, e := range v {
+			size += uint64(len(k)) + sizeOf(e)
+		}
+		return size
+	default:
+		// Numbers, booleans and nulls.
+		return 8
+	}
+}
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"math"
+
+	"github.com/mojixcoder/caster/internal/cache/skiplist"
+)
+
+// ScoredMember is a member of a sorted set along with its score.
+type ScoredMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// ZSet is a sorted set of unique members ordered by their scores.
+// Members are kept in a map for O(1) score lookups and in a skip list for ordered access.
+type ZSet struct {
+	scores map[string]float64
+	list   *skiplist.SkipList
+	size   uint64
+}
+
+// Verifying interface compliance.
+var _ Value = (*ZSet)(nil)
+
+// NewZSet returns a new empty sorted set.
+func NewZSet() *ZSet {
+	return &ZSet{scores: make(map[string]float64), list: skiplist.New()}
+}
+
+// Kind returns the data type of the value.
+func (z *ZSet) Kind() Kind {
+	return KindZSet
+}
+
+// Size returns the approximate memory used by the sorted set in bytes, it's the sum of its members' sizes.
+func (z *ZSet) Size() uint64 {
+	return z.size
+}
+
+// Len returns the number of members.
+func (z *ZSet) Len() int {
+	return len(z.scores)
+}
+
+// Score returns the score of a member.
+func (z *ZSet) Score(member string) (float64, bool) {
+	score, ok := z.scores[member]
+	return score, ok
+}
+
+// Add sets the score of a member and reports whether the member is new.
+func (z *ZSet) Add(member string, score float64) bool {
+	old, exists := z.scores[member]
+	if exists {
+		if old == score {
+			return false
+		}
+		z.list.Delete(member, old)
+	} else {
+		z.size += memberSize(member)
+	}
+
+	z.scores[member] = score
+	z.list.Insert(member, score)
+
+	return !exists
+}
+
+// IncrBy increments the score of a member by delta and returns the new score.
+// A missing member is added with a score of delta.
+// It returns ErrNotANumber if the score would be NaN or overflow to an infinity, which JSON can't encode.
+func (z *ZSet) IncrBy(member string, delta float64) (float64, error) {
+	score := z.scores[member] + delta
+	if math.IsNaN(score) || math.IsInf(score, 0) {
+		return 0, ErrNotANumber
+	}
+
+	z.Add(member, score)
+
+	return score, nil
+}
+
+// Remove removes a member and reports whether it existed.
+func (z *ZSet) Remove(member string) bool {
+	score, exists := z.scores[member]
+	if !exists {
+		return false
+	}
+
+	delete(z.scores, member)
+	z.list.Delete(member, score)
+	z.size -= memberSize(member)
+
+	return true
+}
+
+// Rank returns the 0-based rank of a member, ordered from the lowest score or from the highest if reverse is true.
+func (z *ZSet) Rank(member string, reverse bool) (int, bool) {
+	score, exists := z.scores[member]
+	if !exists {
+		return 0, false
+	}
+
+	rank := z.list.Rank(member, score)
+	if reverse {
+		rank = z.list.Len() - 1 - rank
+	}
+
+	return rank, true
+}
+
+// Range returns the members with ranks between start and stop, both inclusive.
+// Negative ranks count from the end, -1 is the last member. Ranks are ordered from the highest score if reverse is true.
+func (z *ZSet) Range(start, stop int, reverse bool) []ScoredMember {
+	length := z.list.Len()
+
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+
+	if start > stop {
+		return []ScoredMember{}
+	}
+
+	members := make([]ScoredMember, 0, stop-start+1)
+
+	if reverse {
+		for e := z.list.ByRank(length - 1 - start); e != nil && len(members) <= stop-start; e = e.Prev() {
+			members = append(members, ScoredMember{Member: e.Member, Score: e.Score})
+		}
+	} else {
+		for e := z.list.ByRank(start); e != nil && len(members) <= stop-start; e = e.Next() {
+			members = append(members, ScoredMember{Member: e.Member, Score: e.Score})
+		}
+	}
+
+	return members
+}
+
+// RangeByScore returns at most limit members with scores between min and max, both inclusive,
+// skipping the first offset ones. A negative limit returns every member.
+func (z *ZSet) RangeByScore(min, max float64, offset, limit int) []ScoredMember {
+	members := make([]ScoredMember, 0)
+
+	for e := z.list.FirstFrom(min); e != nil && e.Score <= max && limit != 0; e = e.Next() {
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		members = append(members, ScoredMember{Member: e.Member, Score: e.Score})
+		limit--
+	}
+
+	return members
+}
+
+// memberSize returns the memory accounted for a member.
+func memberSize(member string) uint64 {
+	return uint64(len(member)) + 8
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"math"
+	"testing"
+)
+
+func TestZSetIncrBy(t *testing.T) {
+	tests := []struct {
+		name  string
+		start float64
+		delta float64
+		score float64
+		err   error
+	}{
+		{name: "increment", start: 1, delta: 2.5, score: 3.5},
+		{name: "decrement", start: 1, delta: -3, score: -2},
+		{name: "overflow", start: math.MaxFloat64, delta: math.MaxFloat64, err: ErrNotANumber},
+		{name: "negative overflow", start: -math.MaxFloat64, delta: -math.MaxFloat64, err: ErrNotANumber},
+		{name: "infinity", start: 1, delta: math.Inf(1), err: ErrNotANumber},
+		{name: "not a number", start: 1, delta: math.NaN(), err: ErrNotANumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			z := NewZSet()
+			z.Add("member", tt.start)
+
+			score, err := z.IncrBy("member", tt.delta)
+			if err != tt.err {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			if err != nil {
+				// A failed increment leaves the member as it was.
+				score = tt.start
+			}
+
+			if got, _ := z.Score("member"); got != score || (err == nil && score != tt.score) {
+				t.Errorf("got score %v, want %v", got, score)
+			}
+
+			if rank, ok := z.Rank("member", false); !ok || rank != 0 {
+				t.Errorf("got rank %d %t, want 0", rank, ok)
+			}
+		})
+	}
+}
@@ -1,32 +1,62 @@
 package cluster
 
 import (
+	"context"
 	"errors"
-	"hash"
+	"fmt"
 	"hash/fnv"
-	"sync"
+	"net"
+	"net/url"
+	"sort"
 
 	"github.com/mojixcoder/caster/internal/app"
 	"github.com/mojixcoder/caster/internal/config"
+	"github.com/mojixcoder/caster/pkg/keyhash"
 	"go.uber.org/zap"
 )
 
 // Node is a member of cluster.
 type Node struct {
+	// index is node's index, keys are assigned to nodes by their indexes.
+	index int
+
 	// address is node's address.
 	address string
 
+	// rpcAddress is the address of node's RPC server, empty if it has none.
+	rpcAddress string
+
 	// isLocal determines if this is the local node or not.
 	isLocal bool
+
+	// health is the health of the node, which is shared by its copies. It's nil for the local node.
+	health *health
 }
 
+// ErrCrossNode is returned when keys of a multi-key operation live on different nodes.
+var ErrCrossNode = errors.New("keys live on different nodes")
+
 // Cluster does the cluster managament.
 type Cluster struct {
 	// nodeMap maps indexes => nodes.
 	nodeMap map[int]Node
 
-	// pool is a pool of hashes.
-	pool *sync.Pool
+	// epoch identifies the node map, it changes whenever a node is added, removed or moved.
+	epoch uint64
+}
+
+// Topology is the layout of the cluster which clients need to route keys to their nodes.
+type Topology struct {
+	Epoch     uint64         `json:"epoch"`
+	Algorithm string         `json:"algorithm"`
+	Nodes     []TopologyNode `json:"nodes"`
+}
+
+// TopologyNode is a member of the cluster in a topology.
+// Keys whose hash modulo the number of nodes equals the node's index live on the node.
+type TopologyNode struct {
+	Index   int    `json:"index"`
+	Address string `json:"address"`
 }
 
 // IsLocal determines if this node is the local node or not.
@@ -34,19 +64,62 @@ func (n Node) IsLocal() bool {
 	return n.isLocal
 }
 
+// Index returns node's index.
+func (n Node) Index() int {
+	return n.index
+}
+
 // Address returns node's address.
 // It's not used if node is a local node.
 func (n Node) Address() string {
 	return n.address
 }
 
+// RPCAddress returns the address of node's RPC server, empty if it has none.
+func (n Node) RPCAddress() string {
+	return n.rpcAddress
+}
+
 // UpdateNodeMap updates the cluster's node map.
 func (c *Cluster) UpdateNodeMap(nodes []config.NodeConfig) {
 	nodeMap := make(map[int]Node, len(nodes))
 	for _, v := range nodes {
-		nodeMap[v.Index] = Node{address: v.Address, isLocal: v.IsLocal}
+		node := Node{index: v.Index, address: v.Address, rpcAddress: v.RPCAddress, isLocal: v.IsLocal}
+		if !v.IsLocal {
+			node.health = newHealth()
+		}
+		nodeMap[v.Index] = node
 	}
 	c.nodeMap = nodeMap
+	c.epoch = c.Topology().hash()
+}
+
+// Epoch returns the topology epoch of the cluster.
+func (c Cluster) Epoch() uint64 {
+	return c.epoch
+}
+
+// Topology returns the topology of the cluster, nodes are sorted by their indexes.
+func (c Cluster) Topology() Topology {
+	nodes := make([]TopologyNode, 0, len(c.nodeMap))
+	for index, node := range c.nodeMap {
+		nodes = append(nodes, TopologyNode{Index: index, Address: node.Address()})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Index < nodes[j].Index
+	})
+
+	return Topology{Epoch: c.epoch, Algorithm: keyhash.Algorithm, Nodes: nodes}
+}
+
+// hash returns a hash of the topology's nodes, so every node of a cluster computes the same epoch.
+// It's truncated to 53 bits, so it's exact as a JSON number in every language.
+func (t Topology) hash() uint64 {
+	h := fnv.New64a()
+	for _, node := range t.Nodes {
+		fmt.Fprintf(h, "%d=%s;", node.Index, node.Address)
+	}
+	return h.Sum64() & (1<<53 - 1)
 }
 
 // ValidateNodeMap validates node map.
@@ -71,22 +144,33 @@ func (c Cluster) ValidateNodeMap() error {
 
 // GetNodeFromKey gets the node which the operation should be performed on.
 func (c Cluster) GetNodeFromKey(key string) Node {
-	if len(c.nodeMap) == 1 {
-		return c.nodeMap[0]
-	}
+	return c.nodeMap[keyhash.Index(key, len(c.nodeMap))]
+}
 
-	hash := c.pool.Get().(hash.Hash32)
-	defer func() {
-		hash.Reset()
-		c.pool.Put(hash)
-	}()
+// GetNodeFromKeys gets the node which owns all of the keys.
+// It returns ErrCrossNode if the keys live on different nodes.
+func (c Cluster) GetNodeFromKeys(keys []string) (Node, error) {
+	if len(keys) == 0 {
+		return Node{}, errors.New("no key given")
+	}
 
-	hash.Write([]byte(key))
+	node := c.GetNodeFromKey(keys[0])
+	for _, key := range keys[1:] {
+		if c.GetNodeFromKey(key) != node {
+			return Node{}, fmt.Errorf("%w: %q and %q", ErrCrossNode, keys[0], key)
+		}
+	}
 
-	sum := hash.Sum32()
-	sum %= uint32(len(c.nodeMap))
+	return node, nil
+}
 
-	return c.nodeMap[int(sum)]
+// Nodes returns a copy of every node, by their indexes.
+func (c Cluster) Nodes() map[int]Node {
+	nodes := make(map[int]Node, len(c.nodeMap))
+	for k, v := range c.nodeMap {
+		nodes[k] = v
+	}
+	return nodes
 }
 
 // Nodes returns a copy of non-local nodes.
@@ -103,6 +187,49 @@ func (c Cluster) NonLocalNodes() map[int]Node {
 	return nodes
 }
 
+// IsMember determines if the remote address of a connection, as host:port, is the host of a non-local node.
+// Hostnames of nodes are resolved on every call, so it's meant for requests which only nodes send.
+func (c Cluster) IsMember(ctx context.Context, remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, node := range c.nodeMap {
+		if node.IsLocal() {
+			continue
+		}
+
+		u, err := url.Parse(node.Address())
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+
+		if nodeIP := net.ParseIP(u.Hostname()); nodeIP != nil {
+			if nodeIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr.IP.Equal(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // NewCluster returns a new cluster.
 func NewCluster() (Cluster, error) {
 	var cluster Cluster
@@ -127,10 +254,5 @@ func NewCluster() (Cluster, error) {
 		}
 	}
 
-	cluster.pool = new(sync.Pool)
-	cluster.pool.New = func() any {
-		return fnv.New32a()
-	}
-
 	return cluster, nil
 }
@@ -0,0 +1,234 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/config"
+	"go.uber.org/zap"
+)
+
+// HealthPath is the HTTP path which nodes serve for health probes of other nodes.
+const HealthPath = "/health"
+
+const (
+	defaultProbeInterval    = time.Second
+	defaultProbeTimeout     = 500 * time.Millisecond
+	defaultSuspectThreshold = 1
+	defaultDownThreshold    = 3
+	defaultUpThreshold      = 2
+)
+
+const (
+	// StateUp is the state of a node which answers its probes. Nodes are up until they're probed.
+	StateUp State = "up"
+
+	// StateSuspect is the state of a node which has failed its latest probes, but not enough of them to be down.
+	StateSuspect State = "suspect"
+
+	// StateDown is the state of a node which has failed too many consecutive probes.
+	// Calls to it should fail fast until it's up again.
+	StateDown State = "down"
+)
+
+// ErrNodeDown is returned when a node is called while its health probes report it down.
+var ErrNodeDown = errors.New("node is down")
+
+type (
+	// State is the health state of a node.
+	State string
+
+	// Health is the health of a node as seen by the local node.
+	Health struct {
+		State State `json:"state"`
+
+		// Since is the time of the latest state change, nil if the state hasn't changed.
+		Since *time.Time `json:"since,omitempty"`
+
+		// Failures is the number of consecutive failed probes.
+		Failures int `json:"failures,omitempty"`
+
+		// LastError is the error of the latest failed probe.
+		LastError string `json:"last_error,omitempty"`
+	}
+
+	// health keeps the health of a node. It's shared by every copy of the node.
+	health struct {
+		mutex     *sync.Mutex
+		state     State
+		since     time.Time
+		failures  int
+		successes int
+		lastErr   string
+	}
+)
+
+// newHealth returns the health of a node which hasn't been probed yet.
+func newHealth() *health {
+	return &health{mutex: new(sync.Mutex), state: StateUp}
+}
+
+// State returns the health state of the node. The local node is always up.
+func (n Node) State() State {
+	if n.health == nil {
+		return StateUp
+	}
+
+	n.health.mutex.Lock()
+	defer n.health.mutex.Unlock()
+
+	return n.health.state
+}
+
+// IsDown determines if health probes report the node down, so calls to it should fail fast.
+func (n Node) IsDown() bool {
+	return n.State() == StateDown
+}
+
+// Health returns the health of the node.
+func (n Node) Health() Health {
+	if n.health == nil {
+		return Health{State: StateUp}
+	}
+
+	n.health.mutex.Lock()
+	defer n.health.mutex.Unlock()
+
+	health := Health{State: n.health.state, Failures: n.health.failures, LastError: n.health.lastErr}
+	if !n.health.since.IsZero() {
+		since := n.health.since
+		health.Since = &since
+	}
+
+	return health
+}
+
+// prober probes the health of non-local nodes.
+type prober struct {
+	cfg    config.HealthConfig
+	client *http.Client
+}
+
+// StartProbes starts probing the health of every non-local node in the background.
+// A node becomes suspect after SuspectThreshold consecutive failed probes and down after DownThreshold,
+// a node which is down becomes up after UpThreshold consecutive successful probes.
+func (c Cluster) StartProbes() {
+	nodes := c.NonLocalNodes()
+	if len(nodes) == 0 {
+		return
+	}
+
+	cfg := app.App.Config.Health
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultProbeInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultProbeTimeout
+	}
+	if cfg.SuspectThreshold <= 0 {
+		cfg.SuspectThreshold = defaultSuspectThreshold
+	}
+	if cfg.DownThreshold <= 0 {
+		cfg.DownThreshold = defaultDownThreshold
+	}
+	if cfg.DownThreshold < cfg.SuspectThreshold {
+		cfg.DownThreshold = cfg.SuspectThreshold
+	}
+	if cfg.UpThreshold <= 0 {
+		cfg.UpThreshold = defaultUpThreshold
+	}
+
+	app.App.Logger.Info(
+		"starting health probes",
+		zap.Duration("interval", cfg.Interval),
+		zap.Int("suspect_threshold", cfg.SuspectThreshold),
+		zap.Int("down_threshold", cfg.DownThreshold),
+		zap.Int("up_threshold", cfg.UpThreshold),
+	)
+
+	p := prober{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+	for _, node := range nodes {
+		go p.run(node)
+	}
+}
+
+// run probes the node every interval.
+func (p prober) run(node Node) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.record(node, p.probe(node))
+	}
+}
+
+// probe calls the health endpoint of the node.
+func (p prober) probe(node Node) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(node.Address(), "/")+HealthPath, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("health probe responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// record updates the health of the node with the result of a probe.
+func (p prober) record(node Node, err error) {
+	h := node.health
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	state := h.state
+	if err == nil {
+		h.failures = 0
+		h.lastErr = ""
+		h.successes++
+		// A suspect node was never reported down, so a single successful probe clears it.
+		if state == StateSuspect || (state == StateDown && h.successes >= p.cfg.UpThreshold) {
+			state = StateUp
+		}
+	} else {
+		h.successes = 0
+		h.failures++
+		h.lastErr = err.Error()
+		if h.failures >= p.cfg.DownThreshold {
+			state = StateDown
+		} else if h.failures >= p.cfg.SuspectThreshold && state == StateUp {
+			state = StateSuspect
+		}
+	}
+
+	if state == h.state {
+		return
+	}
+
+	h.state = state
+	h.since = time.Now()
+
+	log := app.App.Logger.Info
+	if state != StateUp {
+		log = app.App.Logger.Warn
+	}
+	log("node health changed", zap.String("node", node.Address()), zap.String("state", string(state)), zap.Int("failures", h.failures), zap.Error(err))
+}
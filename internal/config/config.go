@@ -1,28 +1,46 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 // AppConfig holds the entire app configurations.
 type AppConfig struct {
-	Caster *CasterConfig
-	Nodes  []NodeConfig
-	Tracer TracerConfig
+	Caster      *CasterConfig
+	Nodes       []NodeConfig
+	Tracer      TracerConfig
+	Loaders     []LoaderConfig
+	WriteBehind WriteBehindConfig
+	Scripts     ScriptConfig
+	Peer        PeerConfig
+	Handoff     HandoffConfig
+	Health      HealthConfig
 }
 
 // NodeConfig holds nodes configurations.
+// RPCAddress is the host:port of the node's RPC server, nodes without it are called over HTTP.
 type NodeConfig struct {
-	Index   int
-	Address string
-	IsLocal bool `default:"false"`
+	Index      int
+	Address    string
+	RPCAddress string
+	IsLocal    bool `default:"false"`
 }
 
 // CasterConfig is the config of Caster.
+// MaxMemory is the approximate memory limit of cached values in bytes, zero means no limit.
+// RPCPort is the port of the RPC server which other nodes call, zero disables it.
+// GRPCPort is the port of the gRPC API, zero disables it.
+// ExpiryInterval is how often expired keys which aren't accessed are swept.
 type CasterConfig struct {
-	Capacity uint64 `default:"16384"`
-	Port     int    `default:"2376"`
-	Debug    bool   `default:"false"`
+	Capacity       uint64        `default:"16384"`
+	MaxMemory      uint64        `default:"0"`
+	Port           int           `default:"2376"`
+	RPCPort        int           `default:"0"`
+	GRPCPort       int           `default:"0"`
+	ExpiryInterval time.Duration `default:"1s"`
+	Debug          bool          `default:"false"`
 }
 
 // TracerConfig hold tracer configurations.
@@ -32,6 +50,81 @@ type TracerConfig struct {
 	CollectorAddress string
 }
 
+// LoaderConfig holds read-through loader configurations.
+// Keys starting with Prefix are fetched from Origin on a miss.
+// Loaded keys go stale after SoftTTL and expire after HardTTL.
+type LoaderConfig struct {
+	Prefix  string
+	Origin  string
+	Timeout time.Duration `default:"5s"`
+	SoftTTL time.Duration
+	HardTTL time.Duration
+}
+
+// WriteBehindConfig holds write-behind configurations.
+// Writes to keys starting with one of Prefixes are batched and flushed to Sink periodically.
+// Empty Prefixes means every key. Write-behind is disabled when no sink is given.
+type WriteBehindConfig struct {
+	Sink          string
+	Prefixes      []string
+	FlushInterval time.Duration `default:"1s"`
+	BatchSize     int           `default:"100"`
+	QueueSize     int           `default:"10000"`
+	MaxRetries    int           `default:"3"`
+	Backoff       time.Duration `default:"100ms"`
+	Timeout       time.Duration `default:"5s"`
+}
+
+// ScriptConfig holds server-side scripting configurations.
+// Every run of a script is limited by Timeout and MaxSteps, the number of executed instructions.
+type ScriptConfig struct {
+	Timeout  time.Duration `default:"1s"`
+	MaxSteps uint64        `default:"1000000"`
+}
+
+// PeerConfig holds configurations of the HTTP client which nodes use to call each other.
+// MaxIdleConns and MaxConns are per peer, zero MaxConns means no limit.
+// ReadTimeout limits waiting for a response and Timeout limits the whole call, except for streams and long polls.
+// Idempotent calls which fail to reach a peer are retried at most MaxRetries times, negative means no retries.
+// A peer's circuit breaker opens after BreakerThreshold consecutive failures and probes the peer after BreakerCooldown.
+type PeerConfig struct {
+	MaxIdleConns     int           `default:"64"`
+	MaxConns         int           `default:"0"`
+	DialTimeout      time.Duration `default:"1s"`
+	ReadTimeout      time.Duration `default:"5s"`
+	Timeout          time.Duration `default:"10s"`
+	KeepAlive        time.Duration `default:"30s"`
+	IdleConnTimeout  time.Duration `default:"90s"`
+	MaxRetries       int           `default:"2"`
+	RetryBackoff     time.Duration `default:"50ms"`
+	BreakerThreshold int           `default:"5"`
+	BreakerCooldown  time.Duration `default:"5s"`
+}
+
+// HandoffConfig holds hinted handoff configurations.
+// Writes to keys whose owner is unreachable are kept as hints and delivered to the owner every DeliveryInterval.
+// Hints are dropped after TTL and new writes are rejected when the hints take MaxSize bytes.
+// Hinted handoff is disabled unless Enabled is true.
+type HandoffConfig struct {
+	Enabled          bool          `default:"false"`
+	TTL              time.Duration `default:"1h"`
+	MaxSize          int           `default:"67108864"`
+	DeliveryInterval time.Duration `default:"1s"`
+	DeliveryTimeout  time.Duration `default:"5s"`
+}
+
+// HealthConfig holds configurations of the health probes of other nodes.
+// Every non-local node is probed every Interval and a probe fails if it takes longer than Timeout.
+// A node is suspect after SuspectThreshold consecutive failed probes and down after DownThreshold,
+// a node which is down is up again after UpThreshold consecutive successful probes.
+type HealthConfig struct {
+	Interval         time.Duration `default:"1s"`
+	Timeout          time.Duration `default:"500ms"`
+	SuspectThreshold int           `default:"1"`
+	DownThreshold    int           `default:"3"`
+	UpThreshold      int           `default:"2"`
+}
+
 // Load loads the configuration.
 func Load() (*AppConfig, error) {
 	configPath := viper.GetString("config")
@@ -0,0 +1,299 @@
+// Package handoff implements hinted handoff: writes to keys whose owner is unreachable are kept
+// by the node which received them and delivered to the owner once it's reachable again.
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/config"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTTL              = time.Hour
+	defaultMaxSize          = 64 << 20
+	defaultDeliveryInterval = time.Second
+	defaultDeliveryTimeout  = 5 * time.Second
+)
+
+// ErrFull is returned when there is no room left for another hint.
+var ErrFull = errors.New("hinted handoff store is full")
+
+type (
+	// Hint is a write to a key whose owner was unreachable, kept until it's delivered to the owner.
+	Hint struct {
+		// Node is the index of the node which owns the key.
+		Node int
+
+		Key string
+
+		// Value is the JSON encoded value.
+		Value json.RawMessage
+
+		// SoftTTL and HardTTL are the TTLs of the write in milliseconds.
+		SoftTTL int64
+		HardTTL int64
+
+		// Timestamp is the hybrid logical clock timestamp of the write.
+		Timestamp hlc.Timestamp
+
+		// Created is the time of the write.
+		Created time.Time
+	}
+
+	// DeliverFunc delivers a hint to the node which owns its key.
+	// A hint is kept for the next delivery if it returns an error, so errors which retrying can't fix shouldn't be returned.
+	DeliverFunc func(ctx context.Context, h Hint) error
+
+	// Store keeps hints of unreachable nodes and delivers them once the nodes are reachable again.
+	// Only the latest write to a key is kept, hints are dropped after the configured TTL
+	// and new hints are rejected when the hints take the configured size.
+	Store struct {
+		cfg config.HandoffConfig
+
+		mutex *sync.Mutex
+
+		// hints maps node indexes => keys => hints.
+		hints map[int]map[string]Hint
+
+		// size is the total size of hints' keys and values in bytes.
+		size int
+	}
+)
+
+// size returns the size of the hint's key and value in bytes.
+func (h Hint) size() int {
+	return len(h.Key) + len(h.Value)
+}
+
+// expired determines if the hint's key has expired since the write, so there's nothing to deliver.
+func (h Hint) expired(now time.Time) bool {
+	return h.HardTTL > 0 && now.Sub(h.Created) >= time.Duration(h.HardTTL)*time.Millisecond
+}
+
+// Remaining returns the hint with its TTLs reduced by the time passed since the write.
+// A soft TTL which has passed becomes 1ms, so the key is stale as soon as it's delivered.
+func (h Hint) Remaining(now time.Time) Hint {
+	elapsed := now.Sub(h.Created).Milliseconds()
+
+	if h.HardTTL > 0 {
+		h.HardTTL -= elapsed
+	}
+
+	if h.SoftTTL > 0 {
+		h.SoftTTL -= elapsed
+		if h.SoftTTL <= 0 {
+			h.SoftTTL = 1
+		}
+	}
+
+	return h
+}
+
+// NewStore returns a new hint store.
+func NewStore() *Store {
+	cfg := app.App.Config.Handoff
+
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultMaxSize
+	}
+	if cfg.DeliveryInterval <= 0 {
+		cfg.DeliveryInterval = defaultDeliveryInterval
+	}
+	if cfg.DeliveryTimeout <= 0 {
+		cfg.DeliveryTimeout = defaultDeliveryTimeout
+	}
+
+	return &Store{
+		cfg:   cfg,
+		mutex: new(sync.Mutex),
+		hints: make(map[int]map[string]Hint),
+	}
+}
+
+// Enabled determines if hinted handoff is enabled.
+func (s *Store) Enabled() bool {
+	return s.cfg.Enabled
+}
+
+// Add adds a hint, replacing the hint of the same key.
+// It returns ErrFull if there is no room for the hint.
+func (s *Store) Add(h Hint) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	hints := s.hints[h.Node]
+
+	size := s.size + h.size()
+	if old, ok := hints[h.Key]; ok {
+		size -= old.size()
+	}
+
+	if size > s.cfg.MaxSize {
+		return ErrFull
+	}
+
+	if hints == nil {
+		hints = make(map[string]Hint)
+		s.hints[h.Node] = hints
+	}
+	hints[h.Key] = h
+	s.size = size
+
+	return nil
+}
+
+// Drop drops the hint of the key, if there is one.
+// It's called when the key is written on its node directly, so an older hint doesn't overwrite it.
+func (s *Store) Drop(node int, key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.remove(node, key, time.Time{})
+}
+
+// Pending returns the number of hints of every node which has hints.
+func (s *Store) Pending() map[int]int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pending := make(map[int]int, len(s.hints))
+	for node, hints := range s.hints {
+		pending[node] = len(hints)
+	}
+
+	return pending
+}
+
+// Start starts delivering hints in the background.
+// It's a no-op if hinted handoff is disabled.
+func (s *Store) Start(deliver DeliverFunc) {
+	if !s.cfg.Enabled {
+		return
+	}
+
+	app.App.Logger.Info(
+		"starting hinted handoff",
+		zap.Duration("ttl", s.cfg.TTL),
+		zap.Int("max_size", s.cfg.MaxSize),
+		zap.Duration("delivery_interval", s.cfg.DeliveryInterval),
+	)
+
+	go func() {
+		ticker := time.NewTicker(s.cfg.DeliveryInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			s.deliverAll(deliver)
+		}
+	}()
+}
+
+// deliverAll delivers the hints of every node concurrently.
+func (s *Store) deliverAll(deliver DeliverFunc) {
+	s.mutex.Lock()
+	nodes := make([]int, 0, len(s.hints))
+	for node := range s.hints {
+		nodes = append(nodes, node)
+	}
+	s.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(len(nodes))
+	for _, node := range nodes {
+		go func(node int) {
+			defer wg.Done()
+			s.deliverNode(node, deliver)
+		}(node)
+	}
+	wg.Wait()
+}
+
+// deliverNode delivers the hints of a node, oldest first. It stops at the first failure, the node is probably still unreachable.
+func (s *Store) deliverNode(node int, deliver DeliverFunc) {
+	now := time.Now()
+
+	s.mutex.Lock()
+	hints := make([]Hint, 0, len(s.hints[node]))
+	for key, h := range s.hints[node] {
+		if h.expired(now) || now.Sub(h.Created) >= s.cfg.TTL {
+			s.remove(node, key, h.Created)
+			continue
+		}
+		hints = append(hints, h)
+	}
+	s.mutex.Unlock()
+
+	if len(hints) == 0 {
+		return
+	}
+
+	sort.Slice(hints, func(i, j int) bool {
+		return hints[i].Created.Before(hints[j].Created)
+	})
+
+	ctx, span := otel.Tracer(app.App.Config.Tracer.Name).Start(context.Background(), "hinted_handoff")
+	defer span.End()
+
+	var delivered int
+	for _, h := range hints {
+		remaining := h.Remaining(time.Now())
+		if h.HardTTL > 0 && remaining.HardTTL <= 0 {
+			s.mutex.Lock()
+			s.remove(node, h.Key, h.Created)
+			s.mutex.Unlock()
+			continue
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, s.cfg.DeliveryTimeout)
+		err := deliver(callCtx, remaining)
+		cancel()
+
+		if err != nil {
+			app.App.Logger.Debug("error in delivering hint", zap.Int("node", node), zap.String("key", h.Key), zap.Error(err))
+			span.RecordError(err)
+			break
+		}
+
+		s.mutex.Lock()
+		s.remove(node, h.Key, h.Created)
+		s.mutex.Unlock()
+		delivered++
+	}
+
+	span.SetAttributes(attribute.Int("node", node), attribute.Int("delivered", delivered), attribute.Int("pending", len(hints)-delivered))
+
+	if delivered > 0 {
+		app.App.Logger.Info("delivered hints", zap.Int("node", node), zap.Int("count", delivered))
+	}
+}
+
+// remove removes the hint of the key. If created isn't zero, the hint is removed only if it's the same write,
+// so a newer hint added while delivering isn't lost. It must be called while s.mutex is locked.
+func (s *Store) remove(node int, key string, created time.Time) {
+	hints := s.hints[node]
+
+	h, ok := hints[key]
+	if !ok || (!created.IsZero() && !h.Created.Equal(created)) {
+		return
+	}
+
+	delete(hints, key)
+	s.size -= h.size()
+
+	if len(hints) == 0 {
+		delete(s.hints, node)
+	}
+}
@@ -0,0 +1,136 @@
+// Package hlc implements hybrid logical clocks. Their timestamps are close to the wall clock,
+// but unlike it they're strictly increasing and order every write after the writes its node has seen,
+// even if the clocks of the nodes are skewed.
+package hlc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// logicalBits is the number of low bits of a timestamp which hold its logical counter.
+	logicalBits = 16
+
+	// MaxTimestamp is the largest timestamp. Clocks saturate at it instead of wrapping to zero.
+	MaxTimestamp = Timestamp(1<<64 - 1)
+)
+
+// ErrTooFarAhead is returned when a received timestamp is further ahead of the physical clock than the clock accepts.
+var ErrTooFarAhead = errors.New("timestamp is too far ahead of the clock")
+
+type (
+	// Timestamp is a hybrid logical clock timestamp. Its high 48 bits are the physical time
+	// in Unix milliseconds and its low 16 bits are a counter of events in the same millisecond.
+	// Timestamps are ordered like integers, zero means no timestamp.
+	Timestamp uint64
+
+	// Clock is a hybrid logical clock.
+	Clock struct {
+		mutex    *sync.Mutex
+		last     Timestamp
+		maxDrift time.Duration
+		now      func() time.Time
+	}
+)
+
+// NewTimestamp returns the timestamp of the physical time and the logical counter.
+func NewTimestamp(physical time.Time, logical uint16) Timestamp {
+	return Timestamp(uint64(physical.UnixMilli())<<logicalBits | uint64(logical))
+}
+
+// Physical returns the physical time of the timestamp.
+func (t Timestamp) Physical() time.Time {
+	return time.UnixMilli(int64(t >> logicalBits))
+}
+
+// Logical returns the logical counter of the timestamp.
+func (t Timestamp) Logical() uint16 {
+	return uint16(t)
+}
+
+// String returns the timestamp as "<unix milliseconds>.<logical counter>".
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%d.%d", uint64(t>>logicalBits), t.Logical())
+}
+
+// MarshalText encodes the timestamp like String, so it's exact in JSON in every language.
+func (t Timestamp) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes a timestamp encoded by MarshalText.
+func (t *Timestamp) UnmarshalText(text []byte) error {
+	physical, logical, ok := strings.Cut(string(text), ".")
+	if !ok {
+		return fmt.Errorf("invalid timestamp %q", text)
+	}
+
+	ms, err := strconv.ParseUint(physical, 10, 64-logicalBits)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q: %w", text, err)
+	}
+
+	counter, err := strconv.ParseUint(logical, 10, logicalBits)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q: %w", text, err)
+	}
+
+	*t = Timestamp(ms<<logicalBits | counter)
+	return nil
+}
+
+// NewClock returns a new clock which uses the wall clock as its physical clock.
+// Received timestamps more than maxDrift ahead of the wall clock are rejected, so a single bad timestamp
+// can't push the clock far into the future.
+func NewClock(maxDrift time.Duration) *Clock {
+	return &Clock{mutex: new(sync.Mutex), maxDrift: maxDrift, now: time.Now}
+}
+
+// Now returns the timestamp of a local event, like a write received from a client.
+// It's greater than every timestamp returned or seen by the clock before, unless the clock has saturated at MaxTimestamp.
+func (c *Clock) Now() Timestamp {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.tick(c.last)
+}
+
+// Update merges the timestamp of an event received from another node into the clock and returns
+// the timestamp of receiving it, which is greater than both the received timestamp and the clock's last one.
+// It returns ErrTooFarAhead and leaves the clock as is if the timestamp is more than the clock's max drift ahead of it.
+func (c *Clock) Update(ts Timestamp) (Timestamp, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if limit := NewTimestamp(c.now().Add(c.maxDrift), 1<<logicalBits-1); ts > limit {
+		return 0, fmt.Errorf("%w: %s", ErrTooFarAhead, ts)
+	}
+
+	if c.last > ts {
+		ts = c.last
+	}
+
+	return c.tick(ts), nil
+}
+
+// tick advances the clock past the given timestamp.
+// It must be called while c.mutex is locked.
+func (c *Clock) tick(past Timestamp) Timestamp {
+	if physical := NewTimestamp(c.now(), 0); physical > past {
+		c.last = physical
+	} else if past < MaxTimestamp {
+		// The physical clock is behind, so the logical counter orders the event.
+		// A full counter carries into the physical time.
+		c.last = past + 1
+	} else {
+		// The clock is saturated, wrapping would make it zero which means no timestamp.
+		c.last = past
+	}
+
+	return c.last
+}
@@ -0,0 +1,160 @@
+package hlc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a physical clock which only moves when it's set.
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+// newTestClock returns a clock whose physical clock is fake.
+func newTestClock(maxDrift time.Duration) (*Clock, *fakeClock) {
+	physical := &fakeClock{now: time.UnixMilli(1700000000000)}
+	c := NewClock(maxDrift)
+	c.now = physical.Now
+	return c, physical
+}
+
+func TestClockNow(t *testing.T) {
+	c, physical := newTestClock(time.Minute)
+	start := physical.now
+
+	if ts := c.Now(); ts != NewTimestamp(start, 0) {
+		t.Fatalf("got %s, want %s", ts, NewTimestamp(start, 0))
+	}
+
+	// The physical clock doesn't move, so the logical counter does.
+	if ts := c.Now(); ts != NewTimestamp(start, 1) {
+		t.Fatalf("got %s, want %s", ts, NewTimestamp(start, 1))
+	}
+
+	physical.now = start.Add(time.Millisecond)
+	if ts := c.Now(); ts != NewTimestamp(physical.now, 0) {
+		t.Fatalf("got %s after the physical clock moved, want %s", ts, NewTimestamp(physical.now, 0))
+	}
+
+	// The physical clock going backwards doesn't make timestamps go backwards.
+	last := c.Now()
+	physical.now = start.Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		ts := c.Now()
+		if ts <= last {
+			t.Fatalf("got %s after %s while the physical clock is behind", ts, last)
+		}
+		if ts.Physical() != start.Add(time.Millisecond) {
+			t.Fatalf("got physical time %v, want it to stay at %v", ts.Physical(), start.Add(time.Millisecond))
+		}
+		last = ts
+	}
+}
+
+func TestClockUpdate(t *testing.T) {
+	c, physical := newTestClock(time.Minute)
+	local := c.Now()
+
+	tests := []struct {
+		name string
+		ts   Timestamp
+		want Timestamp
+		err  error
+	}{
+		{name: "behind", ts: NewTimestamp(physical.now.Add(-time.Second), 5), want: local + 1},
+		{name: "ahead", ts: NewTimestamp(physical.now.Add(time.Second), 5), want: NewTimestamp(physical.now.Add(time.Second), 6)},
+		{name: "at the max drift", ts: NewTimestamp(physical.now.Add(time.Minute), 1<<logicalBits-2), want: NewTimestamp(physical.now.Add(time.Minute), 1<<logicalBits-1)},
+		{name: "beyond the max drift", ts: NewTimestamp(physical.now.Add(time.Minute+time.Millisecond), 0), err: ErrTooFarAhead},
+		{name: "max timestamp", ts: MaxTimestamp, err: ErrTooFarAhead},
+	}
+
+	// Every case is applied to the clock after the previous ones.
+	last := local
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := c.Update(tt.ts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				// A rejected timestamp leaves the clock as is.
+				if now := c.Now(); now != last+1 {
+					t.Fatalf("got %s after a rejected timestamp, want %s", now, last+1)
+				}
+				last++
+				return
+			}
+
+			if ts != tt.want || ts <= tt.ts {
+				t.Fatalf("got %s, want %s", ts, tt.want)
+			}
+			last = ts
+		})
+	}
+}
+
+func TestClockCounterOverflow(t *testing.T) {
+	c, physical := newTestClock(time.Minute)
+	c.last = NewTimestamp(physical.now, 1<<logicalBits-1)
+
+	// A full counter carries into the physical time instead of wrapping.
+	want := NewTimestamp(physical.now.Add(time.Millisecond), 0)
+	if ts := c.Now(); ts != want {
+		t.Errorf("got %s, want %s", ts, want)
+	}
+
+	// A saturated clock stays at the max timestamp instead of wrapping to zero.
+	c.last = MaxTimestamp
+	if ts := c.Now(); ts != MaxTimestamp {
+		t.Errorf("got %s from a saturated clock, want %s", ts, MaxTimestamp)
+	}
+}
+
+func TestTimestampText(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   Timestamp
+		text string
+	}{
+		{name: "zero", ts: 0, text: "0.0"},
+		{name: "physical", ts: NewTimestamp(time.UnixMilli(1700000000000), 0), text: "1700000000000.0"},
+		{name: "logical", ts: NewTimestamp(time.UnixMilli(1700000000000), 42), text: "1700000000000.42"},
+		{name: "max", ts: MaxTimestamp, text: "281474976710655.65535"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := tt.ts.MarshalText()
+			if err != nil {
+				t.Fatalf("MarshalText: %v", err)
+			}
+			if string(text) != tt.text {
+				t.Fatalf("got %q, want %q", text, tt.text)
+			}
+
+			var ts Timestamp
+			if err := ts.UnmarshalText(text); err != nil {
+				t.Fatalf("UnmarshalText: %v", err)
+			}
+			if ts != tt.ts {
+				t.Fatalf("got %s, want %s", ts, tt.ts)
+			}
+		})
+	}
+}
+
+func TestTimestampUnmarshalTextInvalid(t *testing.T) {
+	for _, text := range []string{"", "1", "1.", ".1", "a.1", "1.a", "-1.0", "1.-1", "1.65536", "281474976710656.0"} {
+		t.Run(text, func(t *testing.T) {
+			var ts Timestamp
+			if err := ts.UnmarshalText([]byte(text)); err == nil {
+				t.Errorf("got %s, want an error", ts)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package loader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// defaultTimeout is used when a loader has no timeout configured.
+const defaultTimeout = 5 * time.Second
+
+type (
+	// origin is an HTTP origin that keys with the given prefix are loaded from.
+	origin struct {
+		prefix  string
+		url     string
+		timeout time.Duration
+		softTTL time.Duration
+		hardTTL time.Duration
+	}
+
+	// call is an in-flight or completed origin call.
+	call struct {
+		wg  sync.WaitGroup
+		val any
+		err error
+	}
+
+	// Loader loads missing keys from their origins and stores them in the cache.
+	// Concurrent loads of the same key are collapsed into a single origin call.
+	Loader struct {
+		cache   cache.Cache
+		origins []origin
+		client  *http.Client
+
+		mutex *sync.Mutex
+		calls map[string]*call
+	}
+)
+
+// NewLoader returns a new loader using the configured origins.
+func NewLoader(c cache.Cache) *Loader {
+	origins := make([]origin, 0, len(app.App.Config.Loaders))
+	for _, v := range app.App.Config.Loaders {
+		timeout := v.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+
+		origins = append(origins, origin{
+			prefix:  v.Prefix,
+			url:     v.Origin,
+			timeout: timeout,
+			softTTL: v.SoftTTL,
+			hardTTL: v.HardTTL,
+		})
+		app.App.Logger.Info("registered loader", zap.String("prefix", v.Prefix), zap.String("origin", v.Origin))
+	}
+
+	// Longest prefixes first, so the most specific origin wins.
+	sort.SliceStable(origins, func(i, j int) bool {
+		return len(origins[i].prefix) > len(origins[j].prefix)
+	})
+
+	return &Loader{
+		cache:   c,
+		origins: origins,
+		client:  &http.Client{},
+		mutex:   new(sync.Mutex),
+		calls:   make(map[string]*call),
+	}
+}
+
+// originOf returns the origin responsible for the given key.
+func (l *Loader) originOf(key string) (origin, bool) {
+	for _, o := range l.origins {
+		if strings.HasPrefix(key, o.prefix) {
+			return o, true
+		}
+	}
+	return origin{}, false
+}
+
+// Has determines if the key can be loaded from an origin.
+func (l *Loader) Has(key string) bool {
+	_, ok := l.originOf(key)
+	return ok
+}
+
+// Load fetches the key from its origin, stores it in the cache and returns it.
+// If the key is already being loaded, it waits for that call instead of calling the origin again.
+func (l *Loader) Load(ctx context.Context, key string) (any, error) {
+	o, ok := l.originOf(key)
+	if !ok {
+		return nil, cache.ErrNotFound
+	}
+
+	l.mutex.Lock()
+	if c, ok := l.calls[key]; ok {
+		l.mutex.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+
+	c := new(call)
+	c.wg.Add(1)
+	l.calls[key] = c
+	l.mutex.Unlock()
+
+	c.val, c.err = l.fetch(ctx, o, key)
+	if c.err == nil {
+		c.err = l.cache.SetItem(key, cache.NewItem(cache.NewScalar(c.val), o.softTTL, o.hardTTL))
+	}
+	c.wg.Done()
+
+	l.mutex.Lock()
+	delete(l.calls, key)
+	l.mutex.Unlock()
+
+	return c.val, c.err
+}
+
+// Refresh reloads the key from its origin in the background.
+// On failure the cached value is left untouched, so a stale value keeps being served until it expires.
+func (l *Loader) Refresh(ctx context.Context, key string) {
+	go func() {
+		if _, err := l.Load(ctx, key); err != nil {
+			app.App.Logger.Warn("error in refreshing stale key", zap.String("key", key), zap.Error(err))
+		}
+	}()
+}
+
+// fetch calls the origin for the given key.
+func (l *Loader) fetch(ctx context.Context, o origin, key string) (any, error) {
+	// The call is shared by all waiters, so it must not be canceled when the first caller goes away.
+	ctx = tracesdk.ContextWithSpanContext(context.Background(), tracesdk.SpanContextFromContext(ctx))
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	ctx, span := otel.Tracer(app.App.Config.Tracer.Name).Start(ctx, "load_from_origin")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("origin", o.url))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.url+"?key="+url.QueryEscape(key), nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error in creating origin request")
+		return nil, err
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	res, err := l.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error in calling origin")
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		span.SetAttributes(attribute.Bool("key_found", false))
+		return nil, cache.ErrNotFound
+	case res.StatusCode != http.StatusOK:
+		err := fmt.Errorf("origin responded with status %d", res.StatusCode)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "origin responded with an error")
+		return nil, err
+	}
+
+	var val any
+	if err := json.NewDecoder(res.Body).Decode(&val); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error in decoding origin response")
+		return nil, err
+	}
+	span.SetAttributes(attribute.Bool("key_found", true))
+
+	return val, nil
+}
@@ -0,0 +1,134 @@
+package peer
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"go.uber.org/zap"
+)
+
+const (
+	// StateClosed lets every call through.
+	StateClosed State = "closed"
+
+	// StateOpen fails every call fast, until the cooldown passes.
+	StateOpen State = "open"
+
+	// StateHalfOpen lets a single probe call through, which closes the breaker if it succeeds.
+	StateHalfOpen State = "half-open"
+)
+
+// ErrCircuitOpen is returned when a peer is called while its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker of the peer is open")
+
+type (
+	// State is the state of a circuit breaker.
+	State string
+
+	// breaker is the circuit breaker of a peer.
+	// It opens after threshold consecutive failures and lets a probe through after cooldown.
+	breaker struct {
+		mutex     *sync.Mutex
+		peer      string
+		state     State
+		failures  int
+		openedAt  time.Time
+		probing   bool
+		threshold int
+		cooldown  time.Duration
+
+		// now returns the current time, it's replaced in tests.
+		now func() time.Time
+	}
+)
+
+// newBreaker returns a new closed circuit breaker of the peer.
+func newBreaker(peer string, threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		mutex:     new(sync.Mutex),
+		peer:      peer,
+		state:     StateClosed,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state
+}
+
+// allow determines if a call can be made, it returns ErrCircuitOpen if not.
+func (b *breaker) allow() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.setState(StateHalfOpen)
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		// Only the probe is let through.
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// success records a successful call.
+func (b *breaker) success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != StateClosed {
+		b.setState(StateClosed)
+	}
+}
+
+// failure records a failed call.
+func (b *breaker) failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.threshold) {
+		b.openedAt = b.now()
+		b.setState(StateOpen)
+	}
+}
+
+// abandon records a call which was abandoned by its caller, so it says nothing about the peer.
+func (b *breaker) abandon() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.probing = false
+}
+
+// setState changes the state of the breaker and logs it. It must be called while the breaker is locked.
+func (b *breaker) setState(state State) {
+	b.state = state
+
+	log := app.App.Logger.Info
+	if state == StateOpen {
+		log = app.App.Logger.Warn
+	}
+	log("circuit breaker state changed", zap.String("peer", b.peer), zap.String("state", string(state)), zap.Int("failures", b.failures))
+}
@@ -0,0 +1,171 @@
+package peer
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	app.App = &app.AppRepo{Logger: zap.NewNop(), Config: &config.AppConfig{}}
+	os.Exit(m.Run())
+}
+
+// clock is a fake clock which only moves when it's advanced.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// newTestBreaker returns a breaker which uses the fake clock.
+func newTestBreaker(threshold int, cooldown time.Duration) (*breaker, *clock) {
+	clk := &clock{now: time.Unix(1700000000, 0)}
+	b := newBreaker("peer", threshold, cooldown)
+	b.now = clk.Now
+	return b, clk
+}
+
+// call makes a call through the breaker which succeeds or fails.
+func call(t *testing.T, b *breaker, ok bool) {
+	t.Helper()
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("call isn't allowed: %v", err)
+	}
+
+	if ok {
+		b.success()
+	} else {
+		b.failure()
+	}
+}
+
+func TestBreakerThreshold(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Second)
+
+	call(t, b, false)
+	call(t, b, false)
+	if state := b.State(); state != StateClosed {
+		t.Fatalf("got %s below the threshold, want %s", state, StateClosed)
+	}
+
+	// A success resets the consecutive failures.
+	call(t, b, true)
+	call(t, b, false)
+	call(t, b, false)
+	if state := b.State(); state != StateClosed {
+		t.Fatalf("got %s after a success, want %s", state, StateClosed)
+	}
+
+	call(t, b, false)
+	if state := b.State(); state != StateOpen {
+		t.Fatalf("got %s at the threshold, want %s", state, StateOpen)
+	}
+
+	if err := b.allow(); err != ErrCircuitOpen {
+		t.Errorf("got %v from an open breaker, want %v", err, ErrCircuitOpen)
+	}
+}
+
+func TestBreakerCooldown(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe bool
+		state State
+	}{
+		{name: "successful probe", probe: true, state: StateClosed},
+		{name: "failed probe", probe: false, state: StateOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, clk := newTestBreaker(1, time.Second)
+			call(t, b, false)
+
+			clk.Advance(time.Second - time.Nanosecond)
+			if err := b.allow(); err != ErrCircuitOpen {
+				t.Fatalf("got %v before the cooldown, want %v", err, ErrCircuitOpen)
+			}
+
+			clk.Advance(time.Nanosecond)
+			if err := b.allow(); err != nil {
+				t.Fatalf("got %v after the cooldown, want the probe to be allowed", err)
+			}
+			if state := b.State(); state != StateHalfOpen {
+				t.Fatalf("got %s while probing, want %s", state, StateHalfOpen)
+			}
+
+			// Only a single probe is let through.
+			if err := b.allow(); err != ErrCircuitOpen {
+				t.Fatalf("got %v during the probe, want %v", err, ErrCircuitOpen)
+			}
+
+			if tt.probe {
+				b.success()
+			} else {
+				b.failure()
+			}
+
+			if state := b.State(); state != tt.state {
+				t.Fatalf("got %s after the probe, want %s", state, tt.state)
+			}
+
+			// A failed probe starts another cooldown.
+			if !tt.probe {
+				if err := b.allow(); err != ErrCircuitOpen {
+					t.Errorf("got %v right after a failed probe, want %v", err, ErrCircuitOpen)
+				}
+
+				clk.Advance(time.Second)
+				if err := b.allow(); err != nil {
+					t.Errorf("got %v after another cooldown, want the probe to be allowed", err)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerAbandon(t *testing.T) {
+	b, clk := newTestBreaker(1, time.Second)
+
+	// Abandoned calls don't count as failures.
+	for i := 0; i < 3; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("got %v, want the call to be allowed", err)
+		}
+		b.abandon()
+	}
+	if state := b.State(); state != StateClosed {
+		t.Fatalf("got %s after abandoned calls, want %s", state, StateClosed)
+	}
+
+	call(t, b, false)
+	clk.Advance(time.Second)
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("got %v after the cooldown, want the probe to be allowed", err)
+	}
+	b.abandon()
+
+	// An abandoned probe lets another probe through, without closing or opening the breaker.
+	if state := b.State(); state != StateHalfOpen {
+		t.Fatalf("got %s after an abandoned probe, want %s", state, StateHalfOpen)
+	}
+	if err := b.allow(); err != nil {
+		t.Fatalf("got %v after an abandoned probe, want another probe to be allowed", err)
+	}
+	if err := b.allow(); err != ErrCircuitOpen {
+		t.Errorf("got %v during the second probe, want %v", err, ErrCircuitOpen)
+	}
+}
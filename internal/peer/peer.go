@@ -0,0 +1,291 @@
+package peer
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultMaxIdleConns     = 64
+	defaultDialTimeout      = time.Second
+	defaultReadTimeout      = 5 * time.Second
+	defaultTimeout          = 10 * time.Second
+	defaultKeepAlive        = 30 * time.Second
+	defaultIdleConnTimeout  = 90 * time.Second
+	defaultMaxRetries       = 2
+	defaultRetryBackoff     = 50 * time.Millisecond
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 5 * time.Second
+)
+
+type (
+	// idempotentKey is the context key of idempotent calls.
+	idempotentKey struct{}
+
+	// longPollKey is the context key of long polls.
+	longPollKey struct{}
+)
+
+// Client is the HTTP client which nodes use to call each other.
+// It keeps a pool of connections to every peer and never waits for a hung peer forever.
+// Every peer has a circuit breaker, so calls to a peer which is down fail fast.
+type Client struct {
+	// http is used for calls which are limited by the configured timeouts.
+	http *http.Client
+
+	// stream is used for streams and long polls, which are limited by their contexts. It only limits dialing.
+	stream *http.Client
+
+	maxRetries int
+	backoff    time.Duration
+
+	threshold int
+	cooldown  time.Duration
+
+	mutex    *sync.Mutex
+	breakers map[string]*breaker
+}
+
+// orDefault returns val, or def if val isn't positive.
+func orDefault[T int | time.Duration](val, def T) T {
+	if val <= 0 {
+		return def
+	}
+	return val
+}
+
+// NewClient returns a new client using the configured connection pools, timeouts, retries and circuit breakers.
+func NewClient() *Client {
+	cfg := app.App.Config.Peer
+
+	dialer := &net.Dialer{
+		Timeout:   orDefault(cfg.DialTimeout, defaultDialTimeout),
+		KeepAlive: orDefault(cfg.KeepAlive, defaultKeepAlive),
+	}
+
+	newTransport := func() *http.Transport {
+		return &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialer.DialContext,
+			MaxIdleConnsPerHost: orDefault(cfg.MaxIdleConns, defaultMaxIdleConns),
+			MaxConnsPerHost:     cfg.MaxConns,
+			IdleConnTimeout:     orDefault(cfg.IdleConnTimeout, defaultIdleConnTimeout),
+		}
+	}
+
+	transport := newTransport()
+	transport.ResponseHeaderTimeout = orDefault(cfg.ReadTimeout, defaultReadTimeout)
+
+	maxRetries := cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	} else if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	return &Client{
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   orDefault(cfg.Timeout, defaultTimeout),
+		},
+		stream:     &http.Client{Transport: newTransport()},
+		maxRetries: maxRetries,
+		backoff:    orDefault(cfg.RetryBackoff, defaultRetryBackoff),
+		threshold:  orDefault(cfg.BreakerThreshold, defaultBreakerThreshold),
+		cooldown:   orDefault(cfg.BreakerCooldown, defaultBreakerCooldown),
+		mutex:      new(sync.Mutex),
+		breakers:   make(map[string]*breaker),
+	}
+}
+
+// Idempotent marks the calls made with the context as idempotent, so they're retried if they fail.
+// GET calls are always idempotent.
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// LongPoll marks the calls made with the context as long polls, which may wait for a response longer than the timeouts.
+// They're limited by the context instead, which should have a deadline.
+func LongPoll(ctx context.Context) context.Context {
+	return context.WithValue(ctx, longPollKey{}, true)
+}
+
+// isLongPoll determines if the call is a long poll.
+func isLongPoll(req *http.Request) bool {
+	longPoll, _ := req.Context().Value(longPollKey{}).(bool)
+	return longPoll
+}
+
+// isIdempotent determines if the call can be retried.
+func isIdempotent(req *http.Request) bool {
+	idempotent, _ := req.Context().Value(idempotentKey{}).(bool)
+	return idempotent || req.Method == http.MethodGet
+}
+
+// Do sends a request to a peer.
+// Long polls are limited by the request's context instead of the timeouts, other calls are limited by both.
+// Idempotent calls which fail to reach the peer are retried with jittered exponential backoff.
+// It returns ErrCircuitOpen without calling the peer if its circuit breaker is open.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	client := c.http
+	if isLongPoll(req) {
+		client = c.stream
+	}
+
+	retries := 0
+	if isIdempotent(req) && (req.Body == nil || req.GetBody != nil) {
+		retries = c.maxRetries
+	}
+
+	span := trace.SpanFromContext(req.Context())
+	b := c.breaker(req.URL.Host)
+
+	for attempt := 0; ; attempt++ {
+		res, err := c.do(client, b, req)
+		span.SetAttributes(attribute.String("peer.breaker", string(b.State())), attribute.Int("peer.retries", attempt))
+
+		if err == nil || err == ErrCircuitOpen || attempt >= retries || req.Context().Err() != nil {
+			return res, err
+		}
+
+		if !c.wait(req.Context(), attempt) {
+			return nil, err
+		}
+
+		req = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Call makes a call to the peer which isn't made over HTTP, like an RPC call, through the peer's circuit breaker.
+// fn makes a single attempt and reports whether it reached the peer, only attempts which didn't reach it count against the breaker.
+// Idempotent calls whose attempts don't reach the peer are retried like in Do.
+// It returns ErrCircuitOpen without calling fn if the peer's circuit breaker is open.
+func (c *Client) Call(ctx context.Context, peer string, idempotent bool, fn func(ctx context.Context) (reached bool, err error)) error {
+	retries := 0
+	if idempotent {
+		retries = c.maxRetries
+	}
+
+	span := trace.SpanFromContext(ctx)
+	b := c.breaker(peer)
+
+	for attempt := 0; ; attempt++ {
+		reached, err := c.call(ctx, b, fn)
+		span.SetAttributes(attribute.String("peer.breaker", string(b.State())), attribute.Int("peer.retries", attempt))
+
+		if err == nil || reached || err == ErrCircuitOpen || attempt >= retries || ctx.Err() != nil {
+			return err
+		}
+
+		if !c.wait(ctx, attempt) {
+			return err
+		}
+	}
+}
+
+// Stream sends a request to a peer whose response is streamed until the request's context is done.
+// It returns ErrCircuitOpen without calling the peer if its circuit breaker is open.
+func (c *Client) Stream(req *http.Request) (*http.Response, error) {
+	b := c.breaker(req.URL.Host)
+
+	res, err := c.do(c.stream, b, req)
+	trace.SpanFromContext(req.Context()).SetAttributes(attribute.String("peer.breaker", string(b.State())))
+
+	return res, err
+}
+
+// States returns the circuit breaker states of the peers which have been called,
+// by their hosts for HTTP calls and by the peer given to Call for other calls.
+func (c *Client) States() map[string]State {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	states := make(map[string]State, len(c.breakers))
+	for host, b := range c.breakers {
+		states[host] = b.State()
+	}
+
+	return states
+}
+
+// do sends a request once through the breaker. Only failures to reach the peer count against it.
+func (c *Client) do(client *http.Client, b *breaker, req *http.Request) (*http.Response, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(req)
+	switch {
+	case err == nil:
+		b.success()
+	case req.Context().Err() != nil:
+		b.abandon()
+	default:
+		b.failure()
+	}
+
+	return res, err
+}
+
+// call makes an attempt of a call once through the breaker.
+func (c *Client) call(ctx context.Context, b *breaker, fn func(ctx context.Context) (bool, error)) (bool, error) {
+	if err := b.allow(); err != nil {
+		return false, err
+	}
+
+	reached, err := fn(ctx)
+	switch {
+	case err == nil || reached:
+		b.success()
+	case ctx.Err() != nil:
+		b.abandon()
+	default:
+		b.failure()
+	}
+
+	return reached, err
+}
+
+// wait waits for the jittered exponential backoff before retrying the attempt.
+// It returns false if the context is done first.
+func (c *Client) wait(ctx context.Context, attempt int) bool {
+	backoff := c.backoff << attempt
+	backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// breaker returns the circuit breaker of the peer host.
+func (c *Client) breaker(host string) *breaker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b, ok := c.breakers[host]
+	if !ok {
+		b = newBreaker(host, c.threshold, c.cooldown)
+		c.breakers[host] = b
+	}
+
+	return b
+}
@@ -0,0 +1,241 @@
+package peer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/config"
+)
+
+// newTestClient returns a client with the given peer configurations.
+func newTestClient(t *testing.T, cfg config.PeerConfig) *Client {
+	t.Helper()
+
+	old := app.App.Config.Peer
+	app.App.Config.Peer = cfg
+	t.Cleanup(func() { app.App.Config.Peer = old })
+
+	return NewClient()
+}
+
+// flakyServer is a peer which drops the connections of its first failures requests.
+type flakyServer struct {
+	*httptest.Server
+	failures int32
+	requests atomic.Int32
+	bodies   chan string
+}
+
+func newFlakyServer(t *testing.T, failures int) *flakyServer {
+	s := &flakyServer{failures: int32(failures), bodies: make(chan string, 10)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.requests.Add(1) <= s.failures {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		s.bodies <- string(body)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestClientDoRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		idempotent bool
+		failures   int
+		requests   int32
+		ok         bool
+	}{
+		{name: "get succeeds", method: http.MethodGet, failures: 0, requests: 1, ok: true},
+		{name: "get recovers", method: http.MethodGet, failures: 2, requests: 3, ok: true},
+		{name: "get exhausts retries", method: http.MethodGet, failures: 3, requests: 3},
+		{name: "post isn't retried", method: http.MethodPost, failures: 1, requests: 1},
+		{name: "idempotent post recovers", method: http.MethodPost, idempotent: true, failures: 2, requests: 3, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, config.PeerConfig{MaxRetries: 2, RetryBackoff: time.Millisecond, BreakerThreshold: 10})
+			s := newFlakyServer(t, tt.failures)
+
+			ctx := context.Background()
+			if tt.idempotent {
+				ctx = Idempotent(ctx)
+			}
+
+			req, err := http.NewRequestWithContext(ctx, tt.method, s.URL, bytes.NewReader([]byte("body")))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := c.Do(req)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("got %v, want a response", err)
+				}
+				res.Body.Close()
+
+				// Retries send the whole body again.
+				if body := <-s.bodies; body != "body" {
+					t.Errorf("got body %q, want %q", body, "body")
+				}
+			} else if err == nil {
+				res.Body.Close()
+				t.Fatal("got a response, want an error")
+			}
+
+			if requests := s.requests.Load(); requests != tt.requests {
+				t.Errorf("got %d requests, want %d", requests, tt.requests)
+			}
+
+			if state := c.States()[hostOf(t, s.URL)]; state != StateClosed {
+				t.Errorf("got breaker %s, want %s", state, StateClosed)
+			}
+		})
+	}
+}
+
+func TestClientDoBreaker(t *testing.T) {
+	c := newTestClient(t, config.PeerConfig{MaxRetries: -1, BreakerThreshold: 2, BreakerCooldown: time.Second})
+	s := newFlakyServer(t, 3)
+
+	clk := &clock{now: time.Unix(1700000000, 0)}
+	c.breaker(hostOf(t, s.URL)).now = clk.Now
+
+	do := func() error {
+		req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+		res, err := c.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := do(); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("got %v, want the call to fail", err)
+		}
+	}
+
+	// The open breaker fails calls without reaching the peer.
+	if err := do(); err != ErrCircuitOpen {
+		t.Fatalf("got %v, want %v", err, ErrCircuitOpen)
+	}
+	if requests := s.requests.Load(); requests != 2 {
+		t.Fatalf("got %d requests, want 2", requests)
+	}
+
+	// The probe fails, so the breaker opens again.
+	clk.Advance(time.Second)
+	if err := do(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v from the probe, want it to fail", err)
+	}
+	if err := do(); err != ErrCircuitOpen {
+		t.Fatalf("got %v after a failed probe, want %v", err, ErrCircuitOpen)
+	}
+
+	clk.Advance(time.Second)
+	if err := do(); err != nil {
+		t.Fatalf("got %v from the probe, want it to succeed", err)
+	}
+	if state := c.States()[hostOf(t, s.URL)]; state != StateClosed {
+		t.Errorf("got breaker %s, want %s", state, StateClosed)
+	}
+}
+
+func TestClientDoLongPoll(t *testing.T) {
+	c := newTestClient(t, config.PeerConfig{Timeout: 20 * time.Millisecond, MaxRetries: -1})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer s.Close()
+
+	do := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+		res, err := c.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// A deadline alone doesn't lift the timeouts, only marking the call as a long poll does.
+	if err := do(ctx); err == nil {
+		t.Error("got no error from a call which outlived the timeout")
+	}
+
+	if err := do(LongPoll(ctx)); err != nil {
+		t.Errorf("got %v from a long poll, want it to wait for the response", err)
+	}
+}
+
+func TestClientCall(t *testing.T) {
+	errUnreachable := errors.New("unreachable")
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name       string
+		idempotent bool
+		results    []error
+		reached    int
+		attempts   int
+		err        error
+	}{
+		{name: "success", idempotent: true, results: []error{nil}, attempts: 1},
+		{name: "idempotent recovers", idempotent: true, results: []error{errUnreachable, errUnreachable, nil}, attempts: 3},
+		{name: "idempotent exhausts retries", idempotent: true, results: []error{errUnreachable, errUnreachable, errUnreachable}, attempts: 3, err: errUnreachable},
+		{name: "not idempotent", results: []error{errUnreachable, nil}, attempts: 1, err: errUnreachable},
+		{name: "reached errors aren't retried", idempotent: true, results: []error{errFailed, nil}, reached: 1, attempts: 1, err: errFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, config.PeerConfig{MaxRetries: 2, RetryBackoff: time.Millisecond, BreakerThreshold: 10})
+
+			var attempts int
+			err := c.Call(context.Background(), "peer:7100", tt.idempotent, func(ctx context.Context) (bool, error) {
+				err := tt.results[attempts]
+				attempts++
+				return attempts <= tt.reached || err == nil, err
+			})
+
+			if err != tt.err {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("got %d attempts, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+// hostOf returns the host of the URL.
+func hostOf(t *testing.T, rawURL string) string {
+	t.Helper()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
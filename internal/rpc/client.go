@@ -0,0 +1,326 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"github.com/mojixcoder/caster/internal/peer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultDialTimeout = time.Second
+	defaultTimeout     = 10 * time.Second
+	defaultKeepAlive   = 30 * time.Second
+)
+
+// errClosed is raised when a call is made on a closed connection.
+var errClosed = errors.New("rpc connection is closed")
+
+type (
+	// Client calls other nodes. It keeps a single persistent connection to every node which all calls share.
+	// Calls go through the circuit breakers of peers, keyed by the nodes' RPC addresses.
+	Client struct {
+		dialer  *net.Dialer
+		timeout time.Duration
+		peers   *peer.Client
+
+		mutex *sync.Mutex
+		conns map[string]*conn
+	}
+
+	// conn is a connection to a node. Requests are written one at a time and responses
+	// are matched to their requests by their IDs, so they can arrive in any order.
+	conn struct {
+		nc net.Conn
+
+		// wlock is held while writing a request. It's a channel, so waiting for it can be cancelled.
+		wlock chan struct{}
+
+		// mutex guards the fields below.
+		mutex   *sync.Mutex
+		nextID  uint32
+		pending map[uint32]chan frame
+		err     error
+
+		// done is closed when the connection is closed.
+		done chan struct{}
+	}
+)
+
+// NewClient returns a new client using the configured dial timeout and timeout of peers.
+// Its calls go through the circuit breakers and retries of peers.
+func NewClient(peers *peer.Client) *Client {
+	cfg := app.App.Config.Peer
+
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultDialTimeout
+	}
+
+	keepAlive := cfg.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = defaultKeepAlive
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Client{
+		dialer:  &net.Dialer{Timeout: dialTimeout, KeepAlive: keepAlive},
+		timeout: timeout,
+		peers:   peers,
+		mutex:   new(sync.Mutex),
+		conns:   make(map[string]*conn),
+	}
+}
+
+// Get gets a key from the node.
+func (c *Client) Get(ctx context.Context, address, key string) (GetResult, error) {
+	res, err := c.call(ctx, address, OpGet, []byte(key), true)
+	if err != nil {
+		return GetResult{}, err
+	}
+
+	return parseGetResultPayload(res)
+}
+
+// Set sets a key on the node, val is the JSON encoded value, TTLs are in milliseconds and ts is the timestamp of the write.
+func (c *Client) Set(ctx context.Context, address, key string, val []byte, softTTL, hardTTL int64, ts hlc.Timestamp) error {
+	_, err := c.call(ctx, address, OpSet, setPayload(key, val, softTTL, hardTTL, ts), false)
+	return err
+}
+
+// Delete deletes a key from the node.
+func (c *Client) Delete(ctx context.Context, address, key string) error {
+	_, err := c.call(ctx, address, OpDelete, []byte(key), true)
+	return err
+}
+
+// Flush flushes the cache of the node.
+func (c *Client) Flush(ctx context.Context, address string) error {
+	_, err := c.call(ctx, address, OpFlush, nil, false)
+	return err
+}
+
+// Close closes every connection.
+func (c *Client) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for address, cn := range c.conns {
+		cn.close(errClosed)
+		delete(c.conns, address)
+	}
+}
+
+// call sends a request to the node and returns the payload of its response.
+// Every attempt is limited by the context's deadline, or the configured timeout if it has none.
+// Idempotent calls are retried if they fail to reach the node.
+func (c *Client) call(ctx context.Context, address string, op Op, payload []byte, idempotent bool) ([]byte, error) {
+	headers := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, headers)
+
+	var res frame
+	var attempts int
+	err := c.peers.Call(ctx, address, idempotent, func(ctx context.Context) (bool, error) {
+		attempts++
+
+		// The timeout is per attempt, so a hung node counts against its breaker unlike a cancelled call.
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+		}
+
+		cn, err := c.conn(ctx, address)
+		if err != nil {
+			return false, err
+		}
+
+		// Any response means the node is reachable, even if the call failed.
+		res, err = cn.call(ctx, frame{op: op, headers: headers, payload: payload})
+		return err == nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The response of an earlier attempt may have been lost after the key was deleted.
+	if op == OpDelete && attempts > 1 && res.status == StatusNotFound {
+		return nil, nil
+	}
+
+	if err := errorOf(res.status, string(res.payload)); err != nil {
+		return nil, err
+	}
+
+	return res.payload, nil
+}
+
+// conn returns the connection to the node, a new one is dialed if there is none or it's closed.
+func (c *Client) conn(ctx context.Context, address string) (*conn, error) {
+	c.mutex.Lock()
+	cn, ok := c.conns[address]
+	c.mutex.Unlock()
+
+	if ok && !cn.closed() {
+		return cn, nil
+	}
+
+	nc, err := c.dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Another call may have dialed the node meanwhile.
+	if cn, ok := c.conns[address]; ok && !cn.closed() {
+		nc.Close()
+		return cn, nil
+	}
+
+	cn = newConn(nc)
+	c.conns[address] = cn
+
+	return cn, nil
+}
+
+// newConn returns a new connection and starts reading its responses.
+func newConn(nc net.Conn) *conn {
+	cn := &conn{
+		nc:      nc,
+		wlock:   make(chan struct{}, 1),
+		mutex:   new(sync.Mutex),
+		pending: make(map[uint32]chan frame),
+		done:    make(chan struct{}),
+	}
+
+	go cn.read()
+
+	return cn
+}
+
+// call sends a request and waits for its response.
+// A call whose request can't be written in time fails alone, unless a part of the request is written,
+// which leaves the connection out of sync and fails every call on it.
+func (cn *conn) call(ctx context.Context, req frame) (frame, error) {
+	ch := make(chan frame, 1)
+
+	cn.mutex.Lock()
+	if cn.err != nil {
+		cn.mutex.Unlock()
+		return frame{}, cn.err
+	}
+	cn.nextID++
+	req.id = cn.nextID
+	cn.pending[req.id] = ch
+	cn.mutex.Unlock()
+
+	defer func() {
+		cn.mutex.Lock()
+		delete(cn.pending, req.id)
+		cn.mutex.Unlock()
+	}()
+
+	buf, err := encodeFrame(req)
+	if err != nil {
+		return frame{}, err
+	}
+
+	select {
+	case cn.wlock <- struct{}{}:
+	case <-ctx.Done():
+		return frame{}, ctx.Err()
+	case <-cn.done:
+		cn.mutex.Lock()
+		defer cn.mutex.Unlock()
+		return frame{}, cn.err
+	}
+
+	deadline, _ := ctx.Deadline()
+	cn.nc.SetWriteDeadline(deadline)
+	n, err := cn.nc.Write(buf)
+	<-cn.wlock
+
+	if err != nil {
+		if n == 0 && errors.Is(err, os.ErrDeadlineExceeded) {
+			return frame{}, err
+		}
+		cn.close(err)
+		return frame{}, err
+	}
+
+	select {
+	case res := <-ch:
+		return res, nil
+	case <-cn.done:
+		cn.mutex.Lock()
+		defer cn.mutex.Unlock()
+		return frame{}, cn.err
+	case <-ctx.Done():
+		return frame{}, ctx.Err()
+	}
+}
+
+// read reads responses and passes them to their calls until the connection is closed.
+func (cn *conn) read() {
+	r := bufio.NewReader(cn.nc)
+
+	for {
+		res, err := readFrame(r)
+		if err != nil {
+			cn.close(err)
+			return
+		}
+
+		cn.mutex.Lock()
+		ch, ok := cn.pending[res.id]
+		cn.mutex.Unlock()
+
+		if ok {
+			ch <- res
+		}
+	}
+}
+
+// closed determines if the connection is closed.
+func (cn *conn) closed() bool {
+	select {
+	case <-cn.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the connection, pending calls fail with err.
+func (cn *conn) close(err error) {
+	cn.mutex.Lock()
+	defer cn.mutex.Unlock()
+
+	if cn.err != nil {
+		return
+	}
+
+	cn.err = err
+	close(cn.done)
+	cn.nc.Close()
+
+	if err != errClosed {
+		app.App.Logger.Debug("rpc connection closed", zap.String("node", cn.nc.RemoteAddr().String()), zap.Error(err))
+	}
+}
@@ -0,0 +1,167 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/config"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"github.com/mojixcoder/caster/internal/peer"
+	"go.uber.org/zap"
+)
+
+// testAddress is the address which the clients of tests call, it's never dialed.
+const testAddress = "node"
+
+// testHandler echoes keys back as values after a random delay, so responses arrive out of order.
+type testHandler struct{}
+
+func (h *testHandler) Get(ctx context.Context, key string) (GetResult, error) {
+	if key == "missing" {
+		return GetResult{}, cache.ErrNotFound
+	}
+
+	time.Sleep(time.Duration(rand.Intn(1000)) * time.Microsecond)
+	return GetResult{Value: []byte(key), Version: uint64(len(key))}, nil
+}
+
+func (h *testHandler) Set(ctx context.Context, key string, val []byte, softTTL, hardTTL int64, ts hlc.Timestamp) error {
+	if key == "" {
+		return &Error{Status: StatusBadRequest, Message: "empty key"}
+	}
+	return nil
+}
+
+func (h *testHandler) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+func (h *testHandler) Flush(ctx context.Context) error {
+	return errors.New("flush failed")
+}
+
+func TestMain(m *testing.M) {
+	app.App = &app.AppRepo{Logger: zap.NewNop(), Config: &config.AppConfig{Caster: &config.CasterConfig{}}}
+	os.Exit(m.Run())
+}
+
+// newTestClient returns a client whose connection to testAddress is served by a server over net.Pipe.
+func newTestClient(t *testing.T, handler Handler) *Client {
+	t.Helper()
+
+	serverEnd, clientEnd := net.Pipe()
+	go (&Server{handler: handler, writeTimeout: time.Second}).serveConn(serverEnd)
+
+	c := NewClient(peer.NewClient())
+	c.conns[testAddress] = newConn(clientEnd)
+	t.Cleanup(c.Close)
+
+	return c
+}
+
+func TestClientConcurrentCalls(t *testing.T) {
+	c := newTestClient(t, &testHandler{})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 500)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			key := fmt.Sprintf("key-%d", i)
+			res, err := c.Get(context.Background(), testAddress, key)
+			if err != nil {
+				errs <- fmt.Errorf("get %s: %w", key, err)
+				return
+			}
+			if string(res.Value) != key || res.Version != uint64(len(key)) {
+				errs <- fmt.Errorf("get %s: got %q version %d", key, res.Value, res.Version)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	c := newTestClient(t, &testHandler{})
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, testAddress, "missing"); err != cache.ErrNotFound {
+		t.Errorf("got %v from get, want %v", err, cache.ErrNotFound)
+	}
+
+	var rpcErr *Error
+	if err := c.Set(ctx, testAddress, "", nil, 0, 0, 0); !errors.As(err, &rpcErr) || rpcErr.Status != StatusBadRequest {
+		t.Errorf("got %v from set, want a bad request", err)
+	}
+
+	if err := c.Flush(ctx, testAddress); !errors.As(err, &rpcErr) || rpcErr.Status != StatusInternal || rpcErr.Message != "flush failed" {
+		t.Errorf("got %v from flush, want an internal error", err)
+	}
+
+	// The connection survives errors of calls.
+	if _, err := c.Get(ctx, testAddress, "key"); err != nil {
+		t.Errorf("got %v from get after errors", err)
+	}
+}
+
+func TestConnWriteTimeout(t *testing.T) {
+	// Nothing reads the other end, so writes block until their deadline.
+	_, clientEnd := net.Pipe()
+	cn := newConn(clientEnd)
+	defer cn.close(errClosed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := cn.call(ctx, frame{op: OpGet, payload: []byte("key")}); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+
+	// Nothing of the request was written, so only the call fails and the connection stays usable.
+	if cn.closed() {
+		t.Error("connection is closed after a call which wrote nothing timed out")
+	}
+}
+
+func TestConnClosed(t *testing.T) {
+	serverEnd, clientEnd := net.Pipe()
+	cn := newConn(clientEnd)
+
+	// The server closes the connection before responding, which fails the pending call.
+	go func() {
+		buf := make([]byte, 1)
+		serverEnd.Read(buf)
+		serverEnd.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := cn.call(ctx, frame{op: OpGet, payload: []byte("key")}); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the error which closed the connection", err)
+	}
+
+	if !cn.closed() {
+		t.Error("connection isn't closed")
+	}
+	if _, err := cn.call(ctx, frame{op: OpGet}); err == nil {
+		t.Error("got no error from a call on a closed connection")
+	}
+}
@@ -0,0 +1,220 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mojixcoder/caster/internal/hlc"
+)
+
+// A frame is laid out as:
+//
+//	length  uint32 // Length of the rest of the frame.
+//	id      uint32 // ID of the request, a response has the ID of its request.
+//	op      uint8
+//	status  uint8  // Always StatusOK in requests.
+//	hdrLen  uint16 // Length of the headers.
+//	headers        // Repeated key length (uint8), key, value length (uint16) and value.
+//	payload        // The rest of the frame, its layout depends on the op.
+//
+// Integers are big-endian.
+const (
+	// frameHeaderSize is the size of the fixed part of a frame after the length.
+	frameHeaderSize = 8
+
+	// maxFrameSize is the maximum length of a frame.
+	maxFrameSize = 256 << 20
+)
+
+const (
+	OpGet Op = iota + 1
+	OpSet
+	OpDelete
+	OpFlush
+)
+
+const (
+	StatusOK Status = iota
+	StatusNotFound
+	StatusWrongType
+	StatusTooLarge
+	StatusUnavailable
+	StatusBadRequest
+	StatusInternal
+	StatusOutdated
+)
+
+// errMalformed is raised when a frame or its payload can't be decoded.
+var errMalformed = errors.New("malformed frame")
+
+type (
+	// Op is the operation of a request.
+	Op uint8
+
+	// Status is the result of a request.
+	Status uint8
+
+	// frame is a request or a response.
+	frame struct {
+		id      uint32
+		op      Op
+		status  Status
+		headers map[string]string
+		payload []byte
+	}
+)
+
+// String returns the name of the op.
+func (op Op) String() string {
+	switch op {
+	case OpGet:
+		return "get"
+	case OpSet:
+		return "set"
+	case OpDelete:
+		return "delete"
+	case OpFlush:
+		return "flush"
+	default:
+		return fmt.Sprintf("op(%d)", uint8(op))
+	}
+}
+
+// encodeFrame encodes the frame, so it's written with a single write.
+func encodeFrame(f frame) ([]byte, error) {
+	var hdr []byte
+	for k, v := range f.headers {
+		if len(k) > 0xff || len(v) > 0xffff {
+			continue
+		}
+		hdr = append(hdr, uint8(len(k)))
+		hdr = append(hdr, k...)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(len(v)))
+		hdr = append(hdr, v...)
+	}
+
+	if len(hdr) > 0xffff {
+		hdr = nil
+	}
+
+	length := frameHeaderSize + len(hdr) + len(f.payload)
+	if length > maxFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes exceeds the limit of %d bytes", length, maxFrameSize)
+	}
+
+	buf := make([]byte, 4+frameHeaderSize, 4+length)
+	binary.BigEndian.PutUint32(buf[0:], uint32(length))
+	binary.BigEndian.PutUint32(buf[4:], f.id)
+	buf[8] = uint8(f.op)
+	buf[9] = uint8(f.status)
+	binary.BigEndian.PutUint16(buf[10:], uint16(len(hdr)))
+
+	buf = append(buf, hdr...)
+	return append(buf, f.payload...), nil
+}
+
+// readFrame reads a frame from r.
+func readFrame(r *bufio.Reader) (frame, error) {
+	var prefix [4 + frameHeaderSize]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return frame{}, err
+	}
+
+	length := binary.BigEndian.Uint32(prefix[0:])
+	hdrLen := int(binary.BigEndian.Uint16(prefix[10:]))
+	if length > maxFrameSize || int(length) < frameHeaderSize+hdrLen {
+		return frame{}, errMalformed
+	}
+
+	rest := make([]byte, int(length)-frameHeaderSize)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return frame{}, err
+	}
+
+	f := frame{
+		id:      binary.BigEndian.Uint32(prefix[4:]),
+		op:      Op(prefix[8]),
+		status:  Status(prefix[9]),
+		payload: rest[hdrLen:],
+	}
+
+	hdr := rest[:hdrLen]
+	for len(hdr) > 0 {
+		klen := int(hdr[0])
+		if len(hdr) < 1+klen+2 {
+			return frame{}, errMalformed
+		}
+		k := string(hdr[1 : 1+klen])
+		vlen := int(binary.BigEndian.Uint16(hdr[1+klen:]))
+		hdr = hdr[1+klen+2:]
+		if len(hdr) < vlen {
+			return frame{}, errMalformed
+		}
+
+		if f.headers == nil {
+			f.headers = make(map[string]string)
+		}
+		f.headers[k] = string(hdr[:vlen])
+		hdr = hdr[vlen:]
+	}
+
+	return f, nil
+}
+
+// setPayload encodes the payload of a set request.
+func setPayload(key string, val []byte, softTTL, hardTTL int64, ts hlc.Timestamp) []byte {
+	buf := make([]byte, 0, 28+len(key)+len(val))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(ts))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(softTTL))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(hardTTL))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(key)))
+	buf = append(buf, key...)
+	return append(buf, val...)
+}
+
+// parseSetPayload decodes the payload of a set request.
+func parseSetPayload(p []byte) (key string, val []byte, softTTL, hardTTL int64, ts hlc.Timestamp, err error) {
+	if len(p) < 28 {
+		return "", nil, 0, 0, 0, errMalformed
+	}
+
+	ts = hlc.Timestamp(binary.BigEndian.Uint64(p[0:]))
+	softTTL = int64(binary.BigEndian.Uint64(p[8:]))
+	hardTTL = int64(binary.BigEndian.Uint64(p[16:]))
+	klen := binary.BigEndian.Uint32(p[24:])
+	if uint32(len(p)-28) < klen {
+		return "", nil, 0, 0, 0, errMalformed
+	}
+
+	return string(p[28 : 28+klen]), p[28+klen:], softTTL, hardTTL, ts, nil
+}
+
+// getResultPayload encodes the payload of a get response.
+func getResultPayload(res GetResult) []byte {
+	buf := make([]byte, 0, 17+len(res.Value))
+	var flags uint8
+	if res.Stale {
+		flags |= 1
+	}
+	buf = append(buf, flags)
+	buf = binary.BigEndian.AppendUint64(buf, res.Version)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(res.Timestamp))
+	return append(buf, res.Value...)
+}
+
+// parseGetResultPayload decodes the payload of a get response.
+func parseGetResultPayload(p []byte) (GetResult, error) {
+	if len(p) < 17 {
+		return GetResult{}, errMalformed
+	}
+
+	return GetResult{
+		Value:     p[17:],
+		Stale:     p[0]&1 != 0,
+		Version:   binary.BigEndian.Uint64(p[1:]),
+		Timestamp: hlc.Timestamp(binary.BigEndian.Uint64(p[9:])),
+	}, nil
+}
@@ -0,0 +1,199 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/hlc"
+)
+
+// rawFrame builds a frame by hand, so it can be inconsistent unlike the frames of encodeFrame.
+func rawFrame(length uint32, hdrLen uint16, rest []byte) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, length)
+	buf = binary.BigEndian.AppendUint32(buf, 1)
+	buf = append(buf, uint8(OpGet), uint8(StatusOK))
+	buf = binary.BigEndian.AppendUint16(buf, hdrLen)
+	return append(buf, rest...)
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame frame
+	}{
+		{name: "empty", frame: frame{id: 1, op: OpFlush}},
+		{name: "payload", frame: frame{id: 2, op: OpGet, payload: []byte("key")}},
+		{name: "status", frame: frame{id: 3, op: OpDelete, status: StatusNotFound, payload: []byte("key not found")}},
+		{
+			name: "headers",
+			frame: frame{
+				id:      4,
+				op:      OpSet,
+				headers: map[string]string{"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "empty": ""},
+				payload: []byte("value"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := encodeFrame(tt.frame)
+			if err != nil {
+				t.Fatalf("encodeFrame: %v", err)
+			}
+
+			r := bufio.NewReader(bytes.NewReader(buf))
+			got, err := readFrame(r)
+			if err != nil {
+				t.Fatalf("readFrame: %v", err)
+			}
+
+			if got.id != tt.frame.id || got.op != tt.frame.op || got.status != tt.frame.status {
+				t.Errorf("got frame %d %s %d, want %d %s %d", got.id, got.op, got.status, tt.frame.id, tt.frame.op, tt.frame.status)
+			}
+			if !bytes.Equal(got.payload, tt.frame.payload) {
+				t.Errorf("got payload %q, want %q", got.payload, tt.frame.payload)
+			}
+			if len(got.headers) != len(tt.frame.headers) || (len(got.headers) > 0 && !reflect.DeepEqual(got.headers, tt.frame.headers)) {
+				t.Errorf("got headers %v, want %v", got.headers, tt.frame.headers)
+			}
+
+			if _, err := readFrame(r); err != io.EOF {
+				t.Errorf("got %v after the frame, want EOF", err)
+			}
+		})
+	}
+}
+
+func TestReadFrameInvalid(t *testing.T) {
+	valid, err := encodeFrame(frame{id: 1, op: OpGet, headers: map[string]string{"k": "v"}, payload: []byte("key")})
+	if err != nil {
+		t.Fatalf("encodeFrame: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		buf  []byte
+		err  error
+	}{
+		{name: "empty", buf: nil, err: io.EOF},
+		{name: "truncated prefix", buf: valid[:6], err: io.ErrUnexpectedEOF},
+		{name: "truncated headers", buf: valid[:14], err: io.ErrUnexpectedEOF},
+		{name: "truncated payload", buf: valid[:len(valid)-1], err: io.ErrUnexpectedEOF},
+		{name: "oversized", buf: rawFrame(maxFrameSize+1, 0, nil), err: errMalformed},
+		{name: "length below header", buf: rawFrame(frameHeaderSize-1, 0, nil), err: errMalformed},
+		{name: "hdrLen beyond length", buf: rawFrame(frameHeaderSize+2, 3, []byte{1, 'k'}), err: errMalformed},
+		{name: "key length beyond headers", buf: rawFrame(frameHeaderSize+3, 3, []byte{5, 'k', 0}), err: errMalformed},
+		{name: "value length beyond headers", buf: rawFrame(frameHeaderSize+5, 5, []byte{1, 'k', 0, 9, 'v'}), err: errMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readFrame(bufio.NewReader(bytes.NewReader(tt.buf)))
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEncodeFrameHeaders(t *testing.T) {
+	// Headers which don't fit their length fields are dropped instead of corrupting the frame.
+	long := string(make([]byte, 0x100))
+	buf, err := encodeFrame(frame{id: 1, op: OpGet, headers: map[string]string{long: "v", "k": "v"}})
+	if err != nil {
+		t.Fatalf("encodeFrame: %v", err)
+	}
+
+	got, err := readFrame(bufio.NewReader(bytes.NewReader(buf)))
+	if err != nil {
+		t.Fatalf("readFrame: %v", err)
+	}
+
+	if want := map[string]string{"k": "v"}; !reflect.DeepEqual(got.headers, want) {
+		t.Errorf("got headers %v, want %v", got.headers, want)
+	}
+}
+
+func TestSetPayload(t *testing.T) {
+	tests := []struct {
+		name             string
+		key              string
+		val              []byte
+		softTTL, hardTTL int64
+		ts               hlc.Timestamp
+	}{
+		{name: "empty", key: "", val: nil},
+		{name: "value", key: "key", val: []byte(`"value"`), softTTL: 1000, hardTTL: 5000, ts: hlc.NewTimestamp(time.UnixMilli(1700000000000), 3)},
+		{name: "negative ttl", key: "key", val: []byte("1"), softTTL: -1, hardTTL: -1, ts: hlc.MaxTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, val, softTTL, hardTTL, ts, err := parseSetPayload(setPayload(tt.key, tt.val, tt.softTTL, tt.hardTTL, tt.ts))
+			if err != nil {
+				t.Fatalf("parseSetPayload: %v", err)
+			}
+
+			if key != tt.key || !bytes.Equal(val, tt.val) || softTTL != tt.softTTL || hardTTL != tt.hardTTL || ts != tt.ts {
+				t.Errorf("got %q %q %d %d %s, want %q %q %d %d %s", key, val, softTTL, hardTTL, ts, tt.key, tt.val, tt.softTTL, tt.hardTTL, tt.ts)
+			}
+		})
+	}
+}
+
+func TestParseSetPayloadInvalid(t *testing.T) {
+	p := setPayload("key", []byte("value"), 1, 2, 3)
+
+	tests := []struct {
+		name string
+		p    []byte
+	}{
+		{name: "empty", p: nil},
+		{name: "truncated header", p: p[:27]},
+		{name: "truncated key", p: p[:30]},
+		{name: "klen overflow", p: binary.BigEndian.AppendUint32(make([]byte, 24), 0xffffffff)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, _, _, err := parseSetPayload(tt.p); err != errMalformed {
+				t.Errorf("got %v, want %v", err, errMalformed)
+			}
+		})
+	}
+}
+
+func TestGetResultPayload(t *testing.T) {
+	tests := []struct {
+		name string
+		res  GetResult
+	}{
+		{name: "empty", res: GetResult{}},
+		{name: "value", res: GetResult{Value: []byte(`"value"`), Version: 7, Timestamp: hlc.NewTimestamp(time.UnixMilli(1700000000000), 1)}},
+		{name: "stale", res: GetResult{Value: []byte("1"), Stale: true, Version: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGetResultPayload(getResultPayload(tt.res))
+			if err != nil {
+				t.Fatalf("parseGetResultPayload: %v", err)
+			}
+
+			if !bytes.Equal(got.Value, tt.res.Value) || got.Stale != tt.res.Stale || got.Version != tt.res.Version || got.Timestamp != tt.res.Timestamp {
+				t.Errorf("got %+v, want %+v", got, tt.res)
+			}
+		})
+	}
+
+	if _, err := parseGetResultPayload(make([]byte, 16)); err != errMalformed {
+		t.Errorf("got %v for a truncated payload, want %v", err, errMalformed)
+	}
+}
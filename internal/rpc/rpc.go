@@ -0,0 +1,87 @@
+// Package rpc is the binary protocol which nodes use to call each other for basic operations.
+// Calls are multiplexed over a persistent TCP connection to every node, so they don't pay for
+// HTTP and JSON re-encoding of values which are forwarded as is.
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"github.com/mojixcoder/caster/internal/writebehind"
+)
+
+type (
+	// Handler handles the calls received by a server.
+	// Values are JSON encoded and passed through as is.
+	Handler interface {
+		Get(ctx context.Context, key string) (GetResult, error)
+		Set(ctx context.Context, key string, val []byte, softTTL, hardTTL int64, ts hlc.Timestamp) error
+		Delete(ctx context.Context, key string) error
+		Flush(ctx context.Context) error
+	}
+
+	// GetResult is the result of a get call.
+	GetResult struct {
+		Value     []byte
+		Stale     bool
+		Version   uint64
+		Timestamp hlc.Timestamp
+	}
+
+	// Error is an error returned by the called node which has no sentinel error.
+	Error struct {
+		Status  Status
+		Message string
+	}
+)
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// statusOf returns the status of an error returned by a handler.
+func statusOf(err error) Status {
+	var rpcErr *Error
+
+	switch {
+	case err == nil:
+		return StatusOK
+	case errors.Is(err, cache.ErrNotFound):
+		return StatusNotFound
+	case errors.Is(err, cache.ErrWrongType):
+		return StatusWrongType
+	case errors.Is(err, cache.ErrTooLarge):
+		return StatusTooLarge
+	case errors.Is(err, cache.ErrOutdated):
+		return StatusOutdated
+	case errors.Is(err, writebehind.ErrQueueFull):
+		return StatusUnavailable
+	case errors.As(err, &rpcErr):
+		return rpcErr.Status
+	default:
+		return StatusInternal
+	}
+}
+
+// errorOf returns the error of a response, its sentinel error if it has one.
+func errorOf(status Status, msg string) error {
+	switch status {
+	case StatusOK:
+		return nil
+	case StatusNotFound:
+		return cache.ErrNotFound
+	case StatusWrongType:
+		return cache.ErrWrongType
+	case StatusTooLarge:
+		return cache.ErrTooLarge
+	case StatusOutdated:
+		return cache.ErrOutdated
+	case StatusUnavailable:
+		return writebehind.ErrQueueFull
+	default:
+		return &Error{Status: status, Message: msg}
+	}
+}
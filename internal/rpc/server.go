@@ -0,0 +1,149 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.uber.org/zap"
+)
+
+// maxInFlight is the maximum number of requests of a connection which are handled at the same time.
+// Reading the connection's requests waits while it's reached, so a flood of requests can't pile up goroutines.
+const maxInFlight = 256
+
+// Server serves the calls of other nodes.
+type Server struct {
+	handler Handler
+
+	// writeTimeout limits writing a response, so a peer which doesn't read its responses can't block them forever.
+	writeTimeout time.Duration
+}
+
+// NewServer returns a new server which passes calls to the handler.
+// Writing a response is limited by the configured timeout of peers.
+func NewServer(handler Handler) *Server {
+	writeTimeout := app.App.Config.Peer.Timeout
+	if writeTimeout <= 0 {
+		writeTimeout = defaultTimeout
+	}
+
+	return &Server{handler: handler, writeTimeout: writeTimeout}
+}
+
+// Serve accepts connections on the listener and serves them until the listener is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go s.serveConn(nc)
+	}
+}
+
+// serveConn serves the requests of a connection concurrently, at most maxInFlight at a time, until the connection is closed.
+func (s *Server) serveConn(nc net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer nc.Close()
+
+	r := bufio.NewReader(nc)
+	wmutex := new(sync.Mutex)
+	sem := make(chan struct{}, maxInFlight)
+
+	for {
+		req, err := readFrame(r)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				app.App.Logger.Warn("error in reading rpc request", zap.String("node", nc.RemoteAddr().String()), zap.Error(err))
+			}
+			return
+		}
+
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+
+			res := s.handle(ctx, req)
+			buf, err := encodeFrame(res)
+			if err != nil {
+				// The response doesn't fit in a frame, the call fails instead.
+				buf, _ = encodeFrame(frame{id: res.id, op: res.op, status: StatusInternal, payload: []byte(err.Error())})
+			}
+
+			wmutex.Lock()
+			defer wmutex.Unlock()
+
+			nc.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+			if _, err := nc.Write(buf); err != nil {
+				app.App.Logger.Warn("error in writing rpc response", zap.String("node", nc.RemoteAddr().String()), zap.Error(err))
+				nc.Close()
+			}
+		}()
+	}
+}
+
+// handle handles a request and returns its response.
+func (s *Server) handle(ctx context.Context, req frame) frame {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(req.headers))
+	ctx, span := otel.Tracer(app.App.Config.Tracer.Name).Start(ctx, "rpc_"+req.op.String())
+	defer span.End()
+
+	res := frame{id: req.id, op: req.op}
+
+	var err error
+	switch req.op {
+	case OpGet:
+		var getRes GetResult
+		if getRes, err = s.handler.Get(ctx, string(req.payload)); err == nil {
+			res.payload = getResultPayload(getRes)
+		}
+	case OpSet:
+		var (
+			key              string
+			val              []byte
+			softTTL, hardTTL int64
+			ts               hlc.Timestamp
+		)
+		if key, val, softTTL, hardTTL, ts, err = parseSetPayload(req.payload); err != nil {
+			err = &Error{Status: StatusBadRequest, Message: err.Error()}
+		} else {
+			err = s.handler.Set(ctx, key, val, softTTL, hardTTL, ts)
+		}
+	case OpDelete:
+		err = s.handler.Delete(ctx, string(req.payload))
+	case OpFlush:
+		err = s.handler.Flush(ctx)
+	default:
+		err = &Error{Status: StatusBadRequest, Message: "unknown op " + req.op.String()}
+	}
+
+	if res.status = statusOf(err); res.status != StatusOK {
+		span.RecordError(err)
+		res.payload = []byte(err.Error())
+	}
+
+	if res.status == StatusInternal {
+		app.App.Logger.Error("error in handling rpc request", zap.Stringer("op", req.op), zap.Error(err))
+		span.SetStatus(codes.Error, "error in handling rpc request")
+	}
+
+	span.SetAttributes(attribute.Int("rpc.status", int(res.status)))
+
+	return res
+}
@@ -0,0 +1,204 @@
+package script
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/cache"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// module is the cache module which is available to scripts.
+var module = &starlarkstruct.Module{
+	Name: "cache",
+	Members: starlark.StringDict{
+		"get":    starlark.NewBuiltin("get", get),
+		"exists": starlark.NewBuiltin("exists", exists),
+		"set":    starlark.NewBuiltin("set", set),
+		"delete": starlark.NewBuiltin("delete", del),
+	},
+}
+
+// currentRun returns the run of the thread.
+func currentRun(thread *starlark.Thread) *run {
+	return thread.Local(runKey).(*run)
+}
+
+// get returns the value of a key, None if it doesn't exist.
+func get(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "key", &key); err != nil {
+		return nil, err
+	}
+
+	val, err := currentRun(thread).get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if val == nil {
+		return starlark.None, nil
+	}
+
+	scalar, ok := val.(cache.Scalar)
+	if !ok {
+		return nil, fmt.Errorf("key %q: %w", key, cache.ErrWrongType)
+	}
+
+	return toStarlark(scalar.Get())
+}
+
+// exists determines if a key exists.
+func exists(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "key", &key); err != nil {
+		return nil, err
+	}
+
+	val, err := currentRun(thread).get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return starlark.Bool(val != nil), nil
+}
+
+// set sets the value of a key with optional TTLs in milliseconds.
+func set(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var (
+		key              string
+		value            starlark.Value
+		softTTL, hardTTL int64
+	)
+	if err := starlark.UnpackArgs(
+		b.Name(), args, kwargs,
+		"key", &key, "value", &value, "soft_ttl_ms?", &softTTL, "hard_ttl_ms?", &hardTTL,
+	); err != nil {
+		return nil, err
+	}
+
+	if softTTL < 0 || hardTTL < 0 || (hardTTL > 0 && softTTL > hardTTL) {
+		return nil, fmt.Errorf("%s: ttls must not be negative and soft ttl must not exceed hard ttl", b.Name())
+	}
+
+	r := currentRun(thread)
+	if _, err := r.get(key); err != nil {
+		return nil, err
+	}
+
+	val, err := fromStarlark(value)
+	if err != nil {
+		return nil, err
+	}
+
+	item := cache.NewItem(cache.NewScalar(val), time.Duration(softTTL)*time.Millisecond, time.Duration(hardTTL)*time.Millisecond)
+	r.changes.change(key, &item)
+
+	return starlark.None, nil
+}
+
+// del deletes a key and reports whether it existed.
+func del(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "key", &key); err != nil {
+		return nil, err
+	}
+
+	r := currentRun(thread)
+	val, err := r.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	r.changes.change(key, nil)
+
+	return starlark.Bool(val != nil), nil
+}
+
+// toStarlark converts a JSON value to a Starlark value. Integral numbers are converted to integers.
+func toStarlark(val any) (starlark.Value, error) {
+	switch v := val.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return starlark.MakeInt64(int64(v)), nil
+		}
+		return starlark.Float(v), nil
+	case []any:
+		elems := make([]starlark.Value, len(v))
+		for i, elem := range v {
+			var err error
+			if elems[i], err = toStarlark(elem); err != nil {
+				return nil, err
+			}
+		}
+		return starlark.NewList(elems), nil
+	case map[string]any:
+		dict := starlark.NewDict(len(v))
+		for k, elem := range v {
+			e, err := toStarlark(elem)
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(k), e); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %T", val)
+	}
+}
+
+// fromStarlark converts a Starlark value to a JSON value.
+func fromStarlark(val starlark.Value) (any, error) {
+	switch v := val.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok {
+			return nil, fmt.Errorf("integer %s is too large", v)
+		}
+		return float64(i), nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.Indexable:
+		elems := make([]any, v.Len())
+		for i := range elems {
+			var err error
+			if elems[i], err = fromStarlark(v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return elems, nil
+	case *starlark.Dict:
+		m := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			k, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dict key %s is not a string", item[0])
+			}
+
+			elem, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[string(k)] = elem
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unsupported value of type %s", val.Type())
+	}
+}
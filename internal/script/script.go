@@ -0,0 +1,293 @@
+package script
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"go.starlark.net/starlark"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultTimeout is used when no script timeout is configured.
+	defaultTimeout = time.Second
+
+	// defaultMaxSteps is used when no script step limit is configured.
+	defaultMaxSteps = 1000000
+
+	// mainFunc is the function of a script which is called on every run.
+	mainFunc = "main"
+
+	// runKey is the thread local key of the current run.
+	runKey = "run"
+)
+
+var (
+	// ErrNotFound is raised when a script isn't loaded.
+	ErrNotFound = errors.New("script not found")
+
+	// ErrNoMain is raised when a script doesn't define its main function.
+	ErrNoMain = errors.New("script must define main(keys, args)")
+)
+
+type (
+	// Error is an error raised by a script, like a syntax error, a runtime error or exceeding its limits.
+	Error struct {
+		err error
+	}
+
+	// Scripts holds the loaded scripts and runs them.
+	// Scripts are written in Starlark, a sandboxed Python dialect which can't access anything but the given keys.
+	Scripts struct {
+		mutex    *sync.RWMutex
+		programs map[string]*starlark.Program
+		timeout  time.Duration
+		maxSteps uint64
+	}
+
+	// Changes are the changes made by a run. They're applied to the cache only if the run succeeds.
+	Changes struct {
+		// keys are the changed keys in order.
+		keys []string
+
+		// items are the new items of changed keys, nil for deleted keys.
+		items map[string]*cache.Item
+	}
+
+	// run is the state of a script run.
+	run struct {
+		tx      cache.Tx
+		keys    map[string]bool
+		changes *Changes
+	}
+)
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// ID returns the ID of a script, it's the hex encoded SHA-1 of its source.
+func ID(src string) string {
+	sum := sha1.Sum([]byte(src))
+	return hex.EncodeToString(sum[:])
+}
+
+// New returns a new empty set of scripts using the configured limits.
+func New() *Scripts {
+	timeout := app.App.Config.Scripts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	maxSteps := app.App.Config.Scripts.MaxSteps
+	if maxSteps == 0 {
+		maxSteps = defaultMaxSteps
+	}
+
+	return &Scripts{
+		mutex:    new(sync.RWMutex),
+		programs: make(map[string]*starlark.Program),
+		timeout:  timeout,
+		maxSteps: maxSteps,
+	}
+}
+
+// Load compiles a script and returns its ID. Loading a loaded script is a no-op.
+func (s *Scripts) Load(src string) (string, error) {
+	id := ID(src)
+	if s.Has(id) {
+		return id, nil
+	}
+
+	_, prog, err := starlark.SourceProgram(id, src, func(name string) bool {
+		return name == "cache"
+	})
+	if err != nil {
+		return "", &Error{err: err}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.programs[id] = prog
+
+	return id, nil
+}
+
+// Has determines if a script is loaded.
+func (s *Scripts) Has(id string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.programs[id]
+	return ok
+}
+
+// Run runs main(keys, args) of a script and returns its result.
+// The script can only access the given keys through tx. Its changes are returned to be applied by the caller.
+func (s *Scripts) Run(ctx context.Context, id string, tx cache.Tx, keys []string, args []any) (any, *Changes, error) {
+	s.mutex.RLock()
+	prog, ok := s.programs[id]
+	s.mutex.RUnlock()
+
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	r := run{
+		tx:      tx,
+		keys:    make(map[string]bool, len(keys)),
+		changes: &Changes{items: make(map[string]*cache.Item)},
+	}
+	for _, key := range keys {
+		r.keys[key] = true
+	}
+
+	thread := &starlark.Thread{
+		Name: id,
+		Print: func(_ *starlark.Thread, msg string) {
+			app.App.Logger.Debug("script printed a message", zap.String("script", id), zap.String("message", msg))
+		},
+	}
+	thread.SetLocal(runKey, &r)
+	thread.SetMaxExecutionSteps(s.maxSteps)
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	globals, err := prog.Init(thread, starlark.StringDict{"cache": module})
+	if err != nil {
+		return nil, nil, &Error{err: err}
+	}
+
+	main, ok := globals[mainFunc].(starlark.Callable)
+	if !ok {
+		return nil, nil, &Error{err: ErrNoMain}
+	}
+
+	keysList, err := toStarlark(toAnySlice(keys))
+	if err != nil {
+		return nil, nil, &Error{err: err}
+	}
+
+	argsList, err := toStarlark(args)
+	if err != nil {
+		return nil, nil, &Error{err: err}
+	}
+
+	res, err := starlark.Call(thread, main, starlark.Tuple{keysList, argsList}, nil)
+	if err != nil {
+		return nil, nil, &Error{err: err}
+	}
+
+	val, err := fromStarlark(res)
+	if err != nil {
+		return nil, nil, &Error{err: err}
+	}
+
+	return val, r.changes, nil
+}
+
+// Sets returns the keys which are set.
+func (c *Changes) Sets() []string {
+	keys := make([]string, 0, len(c.keys))
+	for _, key := range c.keys {
+		if c.items[key] != nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Keys returns the keys which are set or deleted.
+func (c *Changes) Keys() []string {
+	return append([]string(nil), c.keys...)
+}
+
+// Apply applies the changes, the items which are set get the timestamp ts. Either all of them are applied or none of them.
+func (c *Changes) Apply(tx cache.Tx, ts hlc.Timestamp) error {
+	// The values must fit together, otherwise setting the later ones could evict the earlier ones.
+	vals := make([]cache.Value, 0, len(c.keys))
+	for _, key := range c.keys {
+		if item := c.items[key]; item != nil {
+			vals = append(vals, item.Value)
+		}
+	}
+
+	if !tx.Fits(vals...) {
+		return cache.ErrTooLarge
+	}
+
+	for _, key := range c.keys {
+		item := c.items[key]
+		if item == nil {
+			tx.Delete(key)
+			continue
+		}
+
+		item.Timestamp = ts
+		if err := tx.SetItem(key, *item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// change records the new item of a key, nil if it's deleted.
+func (c *Changes) change(key string, item *cache.Item) {
+	if _, ok := c.items[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.items[key] = item
+}
+
+// get returns the value of a key, taking the changes of the run into account.
+func (r *run) get(key string) (cache.Value, error) {
+	if !r.keys[key] {
+		return nil, fmt.Errorf("key %q is not declared", key)
+	}
+
+	if item, ok := r.changes.items[key]; ok {
+		if item == nil {
+			return nil, nil
+		}
+		return item.Value, nil
+	}
+
+	return r.tx.Get(key), nil
+}
+
+// toAnySlice converts a slice of strings to a slice of any.
+func toAnySlice(vals []string) []any {
+	res := make([]any, len(vals))
+	for i, v := range vals {
+		res[i] = v
+	}
+	return res
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/kid"
+	"go.uber.org/zap"
+)
+
+type (
+	BFReserveRequest struct {
+		Key       string  `json:"key"`
+		Capacity  uint64  `json:"capacity"`
+		ErrorRate float64 `json:"error_rate"`
+	}
+
+	BFItemsRequest struct {
+		Key   string   `json:"key"`
+		Items []string `json:"items"`
+	}
+
+	BFAddResponse struct {
+		// Added tells for every item if it's new, i.e. it wasn't probably added before.
+		Added []bool `json:"added"`
+	}
+
+	BFExistsResponse struct {
+		Exists bool `json:"exists"`
+	}
+
+	BFMExistsResponse struct {
+		Exists []bool `json:"exists"`
+	}
+)
+
+var (
+	ErrItemRequired = kid.Map{"message": "item is required."}
+
+	ErrItemsRequired = kid.Map{"message": "at least an item is required."}
+
+	ErrNoItem = errors.New("item is required")
+)
+
+// asBloomFilter returns the value as a Bloom filter.
+func asBloomFilter(val cache.Value) (*cache.BloomFilter, error) {
+	if val == nil {
+		return nil, cache.ErrNotFound
+	}
+
+	bf, ok := val.(*cache.BloomFilter)
+	if !ok {
+		return nil, cache.ErrWrongType
+	}
+
+	return bf, nil
+}
+
+// BFReserve creates an empty Bloom filter sized for the expected number of items and false positive rate.
+func (s Server) BFReserve(c *kid.Context) {
+	ctx, span := getSpan(c, "bfreserve")
+	defer span.End()
+
+	var req BFReserveRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		if val != nil {
+			return nil, cache.ErrExists
+		}
+
+		return cache.NewBloomFilter(req.Capacity, req.ErrorRate)
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in reserving bloom filter")
+		return
+	}
+
+	c.SetResponseHeader("Content-Type", "application/json")
+	c.Byte(http.StatusOK, EmptyResponse)
+}
+
+// BFAdd adds items to a Bloom filter. The filter must be reserved first.
+func (s Server) BFAdd(c *kid.Context) {
+	ctx, span := getSpan(c, "bfadd")
+	defer span.End()
+
+	var req BFItemsRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if len(req.Items) == 0 {
+		span.RecordError(ErrNoItem)
+		c.JSON(http.StatusBadRequest, ErrItemsRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	res := BFAddResponse{Added: make([]bool, len(req.Items))}
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		bf, err := asBloomFilter(val)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, item := range req.Items {
+			res.Added[i] = bf.Add(item)
+		}
+
+		return bf, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in adding bloom filter items")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// BFExists determines if an item has probably been added to a Bloom filter.
+func (s Server) BFExists(c *kid.Context) {
+	ctx, span := getSpan(c, "bfexists")
+	defer span.End()
+
+	key, item := c.QueryParam("key"), c.QueryParam("item")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if item == "" {
+		span.RecordError(ErrNoItem)
+		c.JSON(http.StatusBadRequest, ErrItemRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	var res BFExistsResponse
+	err := s.cache.View(key, func(val cache.Value) error {
+		bf, err := asBloomFilter(val)
+		if err != nil {
+			return err
+		}
+
+		res.Exists = bf.Has(item)
+
+		return nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in checking bloom filter item")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// BFMExists determines for every given item if it has probably been added to a Bloom filter.
+func (s Server) BFMExists(c *kid.Context) {
+	ctx, span := getSpan(c, "bfmexists")
+	defer span.End()
+
+	var req BFItemsRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if len(req.Items) == 0 {
+		span.RecordError(ErrNoItem)
+		c.JSON(http.StatusBadRequest, ErrItemsRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	res := BFMExistsResponse{Exists: make([]bool, len(req.Items))}
+	err = s.cache.View(req.Key, func(val cache.Value) error {
+		bf, err := asBloomFilter(val)
+		if err != nil {
+			return err
+		}
+
+		for i, item := range req.Items {
+			res.Exists[i] = bf.Has(item)
+		}
+
+		return nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in checking bloom filter items")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/peer"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// RedirectHeader is the request header of clients which follow redirects to the owner of a key,
+	// instead of having the request forwarded.
+	RedirectHeader = "X-Caster-Redirect"
+
+	// EpochHeader is the response header which holds the topology epoch of redirects.
+	EpochHeader = "X-Caster-Epoch"
+
+	// TimestampHeader is the request header which holds the timestamp of a write forwarded over HTTP
+	// by the node which coordinates it. It's ignored on requests which don't come from the host of another node,
+	// so writes of clients are always timestamped by the node which receives them.
+	TimestampHeader = "X-Caster-Timestamp"
+)
+
+type (
+	// NodeStatus is the status of a cluster node as seen by this node.
+	NodeStatus struct {
+		Index   int    `json:"index"`
+		Address string `json:"address"`
+		Local   bool   `json:"local"`
+		// Breaker is the state of the node's circuit breakers, the worse of its HTTP and RPC ones. It's empty for the local node.
+		Breaker peer.State `json:"breaker,omitempty"`
+		// Hints is the number of writes to the node which are waiting to be handed off.
+		Hints int `json:"hints,omitempty"`
+		// Health is the health of the node according to its probes, nil for the local node.
+		Health *cluster.Health `json:"health,omitempty"`
+	}
+
+	ClusterStatusResponse struct {
+		Epoch uint64       `json:"epoch"`
+		Nodes []NodeStatus `json:"nodes"`
+	}
+)
+
+// MovedResponse tells the client which node owns the key, so it can send the request there and refresh its topology.
+type MovedResponse struct {
+	Message string `json:"message"`
+	Index   int    `json:"index"`
+	Address string `json:"address"`
+	Epoch   uint64 `json:"epoch"`
+}
+
+// Topology gets the cluster's topology.
+func (s Server) Topology(c *kid.Context) {
+	_, span := getSpan(c, "topology")
+	defer span.End()
+
+	c.JSON(http.StatusOK, s.cluster.Topology())
+}
+
+// ClusterStatus gets the status of every cluster node as seen by this node.
+func (s Server) ClusterStatus(c *kid.Context) {
+	_, span := getSpan(c, "cluster_status")
+	defer span.End()
+
+	states := s.peers.States()
+	pending := s.hints.Pending()
+
+	res := ClusterStatusResponse{Epoch: s.cluster.Epoch(), Nodes: make([]NodeStatus, 0)}
+	for index, node := range s.cluster.Nodes() {
+		status := NodeStatus{Index: index, Address: node.Address(), Local: node.IsLocal(), Hints: pending[index]}
+
+		if !node.IsLocal() {
+			health := node.Health()
+			status.Health = &health
+
+			status.Breaker = peer.StateClosed
+			if u, err := url.Parse(node.Address()); err == nil {
+				status.Breaker = worseState(status.Breaker, states[u.Host])
+			}
+			if address := node.RPCAddress(); address != "" {
+				status.Breaker = worseState(status.Breaker, states[address])
+			}
+		}
+
+		res.Nodes = append(res.Nodes, status)
+	}
+	sort.Slice(res.Nodes, func(i, j int) bool {
+		return res.Nodes[i].Index < res.Nodes[j].Index
+	})
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// Health answers the health probes of other nodes.
+func (s Server) Health(c *kid.Context) {
+	c.SetResponseHeader("Content-Type", "application/json")
+	c.Byte(http.StatusOK, EmptyResponse)
+}
+
+// worseState returns the worse of two circuit breaker states, an empty state is a breaker which hasn't been used.
+func worseState(a, b peer.State) peer.State {
+	rank := map[peer.State]int{peer.StateClosed: 1, peer.StateHalfOpen: 2, peer.StateOpen: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// redirect redirects the request to the node if the client follows redirects.
+// It returns true if the request is redirected.
+func (s Server) redirect(c *kid.Context, span tracesdk.Span, node cluster.Node) bool {
+	if follow, _ := strconv.ParseBool(c.GetRequestHeader(RedirectHeader)); !follow {
+		return false
+	}
+
+	span.SetAttributes(attribute.Bool("redirected", true))
+
+	c.SetResponseHeader(EpochHeader, strconv.FormatUint(s.cluster.Epoch(), 10))
+	c.JSON(http.StatusMisdirectedRequest, &MovedResponse{
+		Message: "moved",
+		Index:   node.Index(),
+		Address: node.Address(),
+		Epoch:   s.cluster.Epoch(),
+	})
+
+	return true
+}
@@ -0,0 +1,466 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/peer"
+	"github.com/mojixcoder/caster/internal/rpc"
+	"github.com/mojixcoder/caster/internal/writebehind"
+	"github.com/mojixcoder/caster/pkg/casterpb"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxFanOut is the maximum number of keys of a multi-key call which are fetched or set at the same time.
+const maxFanOut = 64
+
+type (
+	// grpcService implements the gRPC API. Keys are routed like the HTTP API.
+	grpcService struct {
+		casterpb.UnimplementedCasterServer
+
+		s Server
+	}
+
+	// metadataCarrier adapts gRPC metadata to propagation.TextMapCarrier.
+	metadataCarrier metadata.MD
+
+	// tracedStream is a server stream whose context carries the extracted trace context.
+	tracedStream struct {
+		grpc.ServerStream
+
+		ctx context.Context
+	}
+)
+
+// Get returns the first value of the key.
+func (mc metadataCarrier) Get(key string) string {
+	vals := metadata.MD(mc).Get(key)
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
+
+// Set sets the value of the key.
+func (mc metadataCarrier) Set(key, val string) {
+	metadata.MD(mc).Set(key, val)
+}
+
+// Keys returns the keys of the metadata.
+func (mc metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(mc))
+	for k := range mc {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Context returns the context of the stream.
+func (ts tracedStream) Context() context.Context {
+	return ts.ctx
+}
+
+// extractTrace extracts the trace context of the call from its metadata, like NewTraceMiddleware does for HTTP.
+func extractTrace(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+}
+
+// unaryTraceInterceptor extracts the trace context of unary calls.
+func unaryTraceInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(extractTrace(ctx), req)
+}
+
+// streamTraceInterceptor extracts the trace context of streaming calls.
+func streamTraceInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, tracedStream{ServerStream: ss, ctx: extractTrace(ss.Context())})
+}
+
+// startSpan starts a span of a gRPC call.
+func startSpan(ctx context.Context, name string) (context.Context, tracesdk.Span) {
+	return otel.Tracer(app.App.Config.Tracer.Name).Start(ctx, name)
+}
+
+// grpcError returns the gRPC status of an error. Errors caused by the request are reported to the client, others are logged as internal errors.
+func grpcError(span tracesdk.Span, err error, msg string) error {
+	var rpcErr *rpc.Error
+
+	span.RecordError(err)
+
+	switch {
+	case errors.Is(err, cache.ErrNotFound):
+		span.SetAttributes(attribute.Bool("key_found", false))
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, cache.ErrWrongType):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &rpcErr) && rpcErr.Status == rpc.StatusBadRequest:
+		return status.Error(codes.InvalidArgument, rpcErr.Message)
+	case errors.Is(err, cache.ErrTooLarge):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, cache.ErrOutdated):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, writebehind.ErrQueueFull):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, peer.ErrCircuitOpen), errors.Is(err, cluster.ErrNodeDown):
+		return status.Error(codes.Unavailable, ErrNodeUnavailable["message"].(string))
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		app.App.Logger.Error(msg, zap.Error(err))
+		span.SetStatus(otelcodes.Error, msg)
+		return status.Error(codes.Internal, ErrInternal["message"].(string))
+	}
+}
+
+// validateSet validates a set request.
+func validateSet(req *casterpb.SetRequest) error {
+	if req.GetKey() == "" {
+		return status.Error(codes.InvalidArgument, ErrNoKey.Error())
+	}
+
+	if req.GetSoftTtlMs() < 0 || req.GetHardTtlMs() < 0 || (req.GetHardTtlMs() > 0 && req.GetSoftTtlMs() > req.GetHardTtlMs()) {
+		return status.Error(codes.InvalidArgument, ErrInvalidTTL["message"].(string))
+	}
+
+	return nil
+}
+
+// toValue decodes a JSON value.
+func toValue(val []byte) (*structpb.Value, error) {
+	var v structpb.Value
+	if err := protojson.Unmarshal(val, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// set sets a key to the node which owns it.
+// It returns true if the owner is unreachable and the write is kept as a hint, like writes over HTTP.
+func (g grpcService) set(ctx context.Context, span tracesdk.Span, req *casterpb.SetRequest) (bool, error) {
+	val := []byte("null")
+	if req.GetValue() != nil {
+		var err error
+		if val, err = protojson.Marshal(req.GetValue()); err != nil {
+			return false, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	node := g.s.cluster.GetNodeFromKey(req.GetKey())
+	ts := g.s.clock.Now()
+	if err := g.s.setTo(ctx, node, req.GetKey(), val, req.GetSoftTtlMs(), req.GetHardTtlMs(), ts); err != nil {
+		if node.IsLocal() {
+			return false, err
+		}
+
+		hint := SetRequest{Key: req.GetKey(), Value: val, SoftTTL: req.GetSoftTtlMs(), HardTTL: req.GetHardTtlMs(), Timestamp: ts}
+		if !g.s.hint(ctx, span, node, hint, err) {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if !node.IsLocal() {
+		g.s.hints.Drop(node.Index(), req.GetKey())
+	}
+
+	return false, nil
+}
+
+// fanOut calls fn for every index below n concurrently, at most maxFanOut at a time, and waits for them.
+func fanOut(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxFanOut)
+
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Get gets a key.
+func (g grpcService) Get(ctx context.Context, req *casterpb.GetRequest) (*casterpb.GetResponse, error) {
+	ctx, span := startSpan(ctx, "grpc_get")
+	defer span.End()
+
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, ErrNoKey.Error())
+	}
+
+	node := g.s.cluster.GetNodeFromKey(req.GetKey())
+	span.SetAttributes(attribute.Bool("is_local", node.IsLocal()))
+
+	res, err := g.s.getFrom(ctx, node, req.GetKey())
+	if err != nil {
+		return nil, grpcError(span, err, "error in getting key")
+	}
+
+	val, err := toValue(res.Value)
+	if err != nil {
+		return nil, grpcError(span, err, "error in decoding value")
+	}
+
+	return &casterpb.GetResponse{Value: val, Stale: res.Stale, Version: res.Version, Timestamp: uint64(res.Timestamp)}, nil
+}
+
+// Set sets a key.
+func (g grpcService) Set(ctx context.Context, req *casterpb.SetRequest) (*casterpb.SetResponse, error) {
+	ctx, span := startSpan(ctx, "grpc_set")
+	defer span.End()
+
+	if err := validateSet(req); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	hinted, err := g.set(ctx, span, req)
+	if err != nil {
+		return nil, grpcError(span, err, "error in setting key")
+	}
+
+	return &casterpb.SetResponse{Hinted: hinted}, nil
+}
+
+// Delete deletes a key.
+func (g grpcService) Delete(ctx context.Context, req *casterpb.DeleteRequest) (*casterpb.DeleteResponse, error) {
+	ctx, span := startSpan(ctx, "grpc_delete")
+	defer span.End()
+
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, ErrNoKey.Error())
+	}
+
+	node := g.s.cluster.GetNodeFromKey(req.GetKey())
+	span.SetAttributes(attribute.Bool("is_local", node.IsLocal()))
+
+	if !node.IsLocal() {
+		g.s.hints.Drop(node.Index(), req.GetKey())
+	}
+
+	if err := g.s.deleteFrom(ctx, node, req.GetKey()); err != nil {
+		return nil, grpcError(span, err, "error in deleting key")
+	}
+
+	return &casterpb.DeleteResponse{}, nil
+}
+
+// MGet gets multiple keys concurrently, at most maxFanOut at a time.
+func (g grpcService) MGet(ctx context.Context, req *casterpb.MGetRequest) (*casterpb.MGetResponse, error) {
+	ctx, span := startSpan(ctx, "grpc_mget")
+	defer span.End()
+
+	keys := req.GetKeys()
+	span.SetAttributes(attribute.Int("keys", len(keys)))
+
+	entries := make([]*casterpb.Entry, len(keys))
+	errs := make([]error, len(keys))
+
+	fanOut(len(keys), func(i int) {
+		key := keys[i]
+
+		entries[i] = &casterpb.Entry{Key: key}
+		if key == "" {
+			errs[i] = &rpc.Error{Status: rpc.StatusBadRequest, Message: ErrNoKey.Error()}
+			return
+		}
+
+		res, err := g.s.getFrom(ctx, g.s.cluster.GetNodeFromKey(key), key)
+		if err == cache.ErrNotFound {
+			return
+		}
+		if err != nil {
+			errs[i] = fmt.Errorf("key %q: %w", key, err)
+			return
+		}
+
+		if entries[i].Value, err = toValue(res.Value); err != nil {
+			errs[i] = err
+			return
+		}
+		entries[i].Found = true
+		entries[i].Stale = res.Stale
+		entries[i].Version = res.Version
+		entries[i].Timestamp = uint64(res.Timestamp)
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, grpcError(span, err, "error in getting keys")
+	}
+
+	return &casterpb.MGetResponse{Entries: entries}, nil
+}
+
+// MSet sets multiple keys concurrently, at most maxFanOut at a time. Only validation is all-or-nothing: if an entry is invalid none of them is set,
+// but once they're valid every key is set on its own and some may be set even if others fail.
+func (g grpcService) MSet(ctx context.Context, req *casterpb.MSetRequest) (*casterpb.MSetResponse, error) {
+	ctx, span := startSpan(ctx, "grpc_mset")
+	defer span.End()
+
+	entries := req.GetEntries()
+	span.SetAttributes(attribute.Int("keys", len(entries)))
+
+	for _, entry := range entries {
+		if err := validateSet(entry); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	errs := make([]error, len(entries))
+	hinted := make([]bool, len(entries))
+
+	fanOut(len(entries), func(i int) {
+		var err error
+		if hinted[i], err = g.set(ctx, span, entries[i]); err != nil {
+			errs[i] = fmt.Errorf("key %q: %w", entries[i].GetKey(), err)
+		}
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, grpcError(span, err, "error in setting keys")
+	}
+
+	res := &casterpb.MSetResponse{}
+	for i, entry := range entries {
+		if hinted[i] {
+			res.HintedKeys = append(res.HintedKeys, entry.GetKey())
+		}
+	}
+
+	return res, nil
+}
+
+// Flush clears the cache of the local node, or every node if all is true.
+func (g grpcService) Flush(ctx context.Context, req *casterpb.FlushRequest) (*casterpb.FlushResponse, error) {
+	ctx, span := startSpan(ctx, "grpc_flush")
+	defer span.End()
+
+	span.SetAttributes(attribute.Bool("flush_all", req.GetAll()))
+
+	if err := g.s.cache.Flush(); err != nil {
+		return nil, grpcError(span, err, "error in flushing cache")
+	}
+
+	if req.GetAll() {
+		if err := g.s.flushNodes(ctx); err != nil {
+			return nil, grpcError(span, err, "flushing the cache of some nodes failed")
+		}
+	}
+
+	return &casterpb.FlushResponse{}, nil
+}
+
+// Watch streams keyspace events of keys with the given prefixes.
+// Events of every node are streamed unless local is true.
+func (g grpcService) Watch(req *casterpb.WatchRequest, stream casterpb.Caster_WatchServer) error {
+	ctx, span := startSpan(stream.Context(), "grpc_watch")
+	defer span.End()
+
+	prefixes := req.GetPrefixes()
+	span.SetAttributes(attribute.StringSlice("prefixes", prefixes), attribute.Bool("local", req.GetLocal()))
+
+	sub := g.s.events.Subscribe(streamBufferSize, func(e cache.Event) bool {
+		return e.Op == cache.OpFlush || hasPrefix(e.Key, prefixes)
+	})
+	defer g.s.events.Unsubscribe(sub)
+
+	streamCtx := stream.Context()
+	relayed := make(chan []byte, streamBufferSize)
+
+	if !req.GetLocal() {
+		for _, node := range g.s.cluster.NonLocalNodes() {
+			go g.s.relay(streamCtx, ctx, node, "/subscribe", url.Values{"prefix": prefixes}, relayed)
+		}
+	}
+
+	for {
+		var e cache.Event
+
+		select {
+		case <-streamCtx.Done():
+			return nil
+		case e = <-sub.C:
+		case msg := <-relayed:
+			if err := json.Unmarshal(msg, &e); err != nil {
+				app.App.Logger.Warn("error in decoding relayed event", zap.Error(err))
+				continue
+			}
+		}
+
+		if err := stream.Send(&casterpb.Event{Key: e.Key, Op: string(e.Op), Version: e.Version}); err != nil {
+			return err
+		}
+	}
+}
+
+// runGRPCServer runs the gRPC API, if a gRPC port is configured.
+// It returns the gRPC server so it can be stopped, nil if there is none.
+func (s *Server) runGRPCServer() (*grpc.Server, error) {
+	port := app.App.Config.Caster.GRPCPort
+	if port <= 0 {
+		return nil, nil
+	}
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryTraceInterceptor),
+		grpc.StreamInterceptor(streamTraceInterceptor),
+	)
+	casterpb.RegisterCasterServer(srv, grpcService{s: *s})
+
+	app.App.Logger.Info("running grpc server", zap.String("address", l.Addr().String()))
+
+	go func() {
+		if err := srv.Serve(l); err != nil {
+			app.App.Logger.Error("grpc server stopped", zap.Error(err))
+		}
+	}()
+
+	return srv, nil
+}
+
+// stopGRPCServer stops the gRPC server gracefully, calls which are still running when ctx is done are canceled.
+func stopGRPCServer(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFanOut(t *testing.T) {
+	const n = 10 * maxFanOut
+
+	var running, peak atomic.Int32
+	called := make([]atomic.Bool, n)
+
+	fanOut(n, func(i int) {
+		cur := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			old := peak.Load()
+			if cur <= old || peak.CompareAndSwap(old, cur) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+		called[i].Store(true)
+	})
+
+	if p := peak.Load(); p > maxFanOut {
+		t.Errorf("got %d calls at the same time, want at most %d", p, maxFanOut)
+	}
+	for i := range called {
+		if !called[i].Load() {
+			t.Fatalf("fn isn't called for %d", i)
+		}
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -14,6 +17,9 @@ import (
 	"github.com/mojixcoder/caster/internal/app"
 	"github.com/mojixcoder/caster/internal/cache"
 	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"github.com/mojixcoder/caster/internal/peer"
+	"github.com/mojixcoder/caster/internal/writebehind"
 	"github.com/mojixcoder/kid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -25,12 +31,30 @@ import (
 
 type (
 	GetResponse struct {
-		Value any `json:"value"`
+		Value   any    `json:"value"`
+		Stale   bool   `json:"stale,omitempty"`
+		Version uint64 `json:"version,omitempty"`
+
+		// Timestamp is the timestamp of the write which set the key, it's for debugging.
+		Timestamp hlc.Timestamp `json:"timestamp,omitempty"`
+	}
+
+	DeleteRequest struct {
+		Key string `json:"key"`
 	}
 
+	// SetRequest is the request of setting a key.
+	// Value is kept encoded, so it's decoded only by the node which owns the key.
 	SetRequest struct {
-		Key   string `json:"key"`
-		Value any    `json:"value"`
+		Key     string          `json:"key"`
+		Value   json.RawMessage `json:"value"`
+		SoftTTL int64           `json:"soft_ttl_ms,omitempty"`
+		HardTTL int64           `json:"hard_ttl_ms,omitempty"`
+
+		// Timestamp is the timestamp of the write, it's set by the node which coordinates the write.
+		// Writes which are older than the key's last write are rejected. It's never decoded from the body,
+		// nodes forward it in TimestampHeader.
+		Timestamp hlc.Timestamp `json:"-"`
 	}
 )
 
@@ -43,7 +67,15 @@ var (
 
 	ErrKeyRequired = kid.Map{"message": "key is required."}
 
+	ErrWriteBehindFull = kid.Map{"message": "write-behind queue is full, try again later."}
+
+	ErrInvalidTTL = kid.Map{"message": "ttls must not be negative and soft ttl must not exceed hard ttl."}
+
+	ErrNodeUnavailable = kid.Map{"message": "the node which owns the key is unavailable, try again later."}
+
 	ErrNoKey = errors.New("key is required")
+
+	ErrTTL = errors.New("invalid ttl")
 )
 
 // initHandlers initializes HTTP handlers.
@@ -52,7 +84,68 @@ func (s Server) initHandlers() {
 
 	g.Get("/get", s.GetFromCache)
 	g.Post("/set", s.SetToCache)
+	g.Post("/delete", s.DeleteFromCache)
 	g.Get("/flush", s.FlushCache)
+	g.Get("/subscribe", s.Subscribe)
+
+	g.Post("/channels/publish", s.Publish)
+	g.Get("/channels/subscribe", s.SubscribeChannels)
+
+	g.Post("/hset", s.HSet)
+	g.Get("/hget", s.HGet)
+	g.Post("/hdel", s.HDel)
+	g.Get("/hgetall", s.HGetAll)
+	g.Post("/hincrby", s.HIncrBy)
+
+	g.Post("/lpush", s.LPush)
+	g.Post("/rpush", s.RPush)
+	g.Post("/lpop", s.LPop)
+	g.Post("/rpop", s.RPop)
+	g.Get("/lrange", s.LRange)
+	g.Post("/ltrim", s.LTrim)
+	g.Get("/llen", s.LLen)
+	g.Post("/blpop", s.BLPop)
+	g.Post("/brpop", s.BRPop)
+
+	g.Post("/zadd", s.ZAdd)
+	g.Post("/zincrby", s.ZIncrBy)
+	g.Post("/zrem", s.ZRem)
+	g.Get("/zrange", s.ZRange)
+	g.Get("/zrevrange", s.ZRevRange)
+	g.Get("/zrangebyscore", s.ZRangeByScore)
+	g.Get("/zrank", s.ZRank)
+
+	g.Post("/sadd", s.SAdd)
+	g.Post("/srem", s.SRem)
+	g.Get("/sismember", s.SIsMember)
+	g.Get("/smembers", s.SMembers)
+	g.Get("/scard", s.SCard)
+	g.Get("/sinter", s.SInter)
+	g.Get("/sunion", s.SUnion)
+	g.Get("/sdiff", s.SDiff)
+
+	g.Post("/pfadd", s.PFAdd)
+	g.Get("/pfcount", s.PFCount)
+	g.Post("/pfmerge", s.PFMerge)
+	g.Get("/pfregisters", s.PFRegisters)
+
+	g.Post("/bfreserve", s.BFReserve)
+	g.Post("/bfadd", s.BFAdd)
+	g.Get("/bfexists", s.BFExists)
+	g.Post("/bfmexists", s.BFMExists)
+
+	g.Post("/ratelimit", s.RateLimit)
+
+	g.Post("/tx", s.Tx)
+
+	g.Post("/scripts/load", s.LoadScript)
+	g.Post("/scripts/run", s.RunScript)
+
+	g.Get("/cluster/topology", s.Topology)
+	g.Get("/cluster/status", s.ClusterStatus)
+
+	// Health probes aren't traced, other nodes send them every probe interval.
+	s.kid.Get(cluster.HealthPath, s.Health)
 }
 
 func (s Server) mergeAddressAndPath(address, path string) string {
@@ -74,6 +167,240 @@ func injectReq(ctx context.Context, req *http.Request) *http.Request {
 	return req
 }
 
+// readJSON reads the JSON request body into out and returns the raw body, so it can be forwarded as is.
+func readJSON(c *kid.Context, out any) ([]byte, error) {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, json.Unmarshal(body, out)
+}
+
+// queryInt parses an integer query parameter, def is returned if it's not given.
+func queryInt(c *kid.Context, name string, def int) (int, error) {
+	param := c.QueryParam(name)
+	if param == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(param)
+}
+
+// queryFloat parses a float query parameter, def is returned if it's not given.
+// Infinities are given as -inf and +inf, an unescaped + is decoded as a space so it's trimmed.
+// NaN is rejected with cache.ErrNotANumber, as it isn't ordered.
+func queryFloat(c *kid.Context, name string, def float64) (float64, error) {
+	param := strings.TrimSpace(c.QueryParam(name))
+	if param == "" {
+		return def, nil
+	}
+
+	val, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if math.IsNaN(val) {
+		return 0, cache.ErrNotANumber
+	}
+
+	return val, nil
+}
+
+// cacheError writes the response of an error returned by the cache.
+// Errors caused by the request are reported to the client, others are logged as internal errors.
+func cacheError(c *kid.Context, span tracesdk.Span, err error, msg string) {
+	switch err {
+	case cache.ErrNotFound:
+		span.SetAttributes(attribute.Bool("key_found", false))
+		c.JSON(http.StatusNotFound, ErrNotFound)
+	case cache.ErrWrongType, cache.ErrNotInteger, cache.ErrOverflow, cache.ErrNotANumber, cache.ErrInvalidRegisters, cache.ErrInvalidBloomParams:
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+	case cache.ErrExists, cache.ErrOutdated:
+		span.RecordError(err)
+		c.JSON(http.StatusConflict, kid.Map{"message": err.Error()})
+	case cache.ErrTooLarge:
+		span.RecordError(err)
+		c.JSON(http.StatusRequestEntityTooLarge, kid.Map{"message": err.Error()})
+	default:
+		app.App.Logger.Error(msg, zap.Error(err))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, msg)
+		c.JSON(http.StatusInternalServerError, ErrInternal)
+	}
+}
+
+// route forwards the request to the node which owns the key, unless it's the local node.
+// It returns true if the request should be handled locally.
+func (s Server) route(ctx context.Context, c *kid.Context, span tracesdk.Span, key string, body []byte) bool {
+	return s.routeTo(ctx, c, span, s.cluster.GetNodeFromKey(key), body)
+}
+
+// routeKeys forwards the request to the node which owns all of the keys, unless it's the local node.
+// It returns true if the request should be handled locally.
+// The keys must live on the same node, otherwise the request is rejected.
+func (s Server) routeKeys(ctx context.Context, c *kid.Context, span tracesdk.Span, keys []string, body []byte) bool {
+	node, err := s.cluster.GetNodeFromKeys(keys)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return false
+	}
+
+	return s.routeTo(ctx, c, span, node, body)
+}
+
+// routeWrite is like routeKeys for requests which write some of the keys.
+// Once the node which owns the keys applies the request, pending hints of the written keys are dropped,
+// as they're older than the request. written returns the written keys, given the body of the node's response.
+func (s Server) routeWrite(
+	ctx context.Context,
+	c *kid.Context,
+	span tracesdk.Span,
+	keys []string,
+	body []byte,
+	written func(res []byte) []string,
+) bool {
+	node, err := s.cluster.GetNodeFromKeys(keys)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return false
+	}
+
+	isLocal := node.IsLocal()
+
+	span.SetAttributes(attribute.Bool("is_local", isLocal))
+
+	if !isLocal && !s.redirect(c, span, node) {
+		if res, ok := s.forward(ctx, c, span, node, body); ok {
+			for _, key := range written(res) {
+				s.hints.Drop(node.Index(), key)
+			}
+		}
+	}
+
+	return isLocal
+}
+
+// routeTo forwards the request to the node, unless it's the local node.
+// It returns true if the request should be handled locally.
+func (s Server) routeTo(ctx context.Context, c *kid.Context, span tracesdk.Span, node cluster.Node, body []byte) bool {
+	isLocal := node.IsLocal()
+
+	span.SetAttributes(attribute.Bool("is_local", isLocal))
+
+	if !isLocal && !s.redirect(c, span, node) {
+		s.forward(ctx, c, span, node, body)
+	}
+
+	return isLocal
+}
+
+// broadcast posts the body to the path of every non-local node concurrently.
+func (s Server) broadcast(ctx context.Context, path string, body []byte) error {
+	nodes := s.cluster.NonLocalNodes()
+	var wg sync.WaitGroup
+	var i int
+	errs := make([]error, len(nodes))
+
+	wg.Add(len(nodes))
+	for _, node := range nodes {
+		go func(i int, node cluster.Node) {
+			defer wg.Done()
+
+			req, _ := http.NewRequest(http.MethodPost, s.mergeAddressAndPath(node.Address(), path), bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req = injectReq(ctx, req)
+
+			res, err := s.peers.Do(req)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			res.Body.Close()
+
+			if res.StatusCode != http.StatusOK {
+				errs[i] = fmt.Errorf("cluster member %s responded with status %d", node.Address(), res.StatusCode)
+			}
+		}(i, node)
+		i++
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// peerError writes the response of a failed call to another node.
+// Calls to a node whose circuit breaker is open fail fast with 503.
+func peerError(c *kid.Context, span tracesdk.Span, node cluster.Node, path string, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, "error in calling a cluster member")
+
+	if errors.Is(err, peer.ErrCircuitOpen) || errors.Is(err, cluster.ErrNodeDown) {
+		app.App.Logger.Warn("cluster member is unavailable", zap.String("node", node.Address()), zap.String("path", path))
+		c.JSON(http.StatusServiceUnavailable, ErrNodeUnavailable)
+		return
+	}
+
+	app.App.Logger.Error(
+		"error in calling a cluster member",
+		zap.String("node", node.Address()),
+		zap.String("path", path),
+		zap.Error(err),
+	)
+	c.JSON(http.StatusInternalServerError, ErrInternal)
+}
+
+// forward forwards the request to another node and writes the node's response back.
+// It returns the body of the node's response and whether the node succeeded.
+func (s Server) forward(ctx context.Context, c *kid.Context, span tracesdk.Span, node cluster.Node, body []byte) ([]byte, bool) {
+	path := c.Request().URL.Path
+	address := s.mergeAddressAndPath(node.Address(), path)
+	if query := c.Request().URL.RawQuery; query != "" {
+		address += "?" + query
+	}
+
+	if node.IsDown() {
+		peerError(c, span, node, path, cluster.ErrNodeDown)
+		return nil, false
+	}
+
+	app.App.Logger.Debug("forwarding request to another node", zap.String("node", node.Address()), zap.String("path", path))
+
+	req, _ := http.NewRequest(c.Request().Method, address, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = injectReq(ctx, req)
+
+	res, err := s.peers.Do(req)
+	if err != nil {
+		peerError(c, span, node, path, err)
+		return nil, false
+	}
+	defer res.Body.Close()
+
+	bytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		app.App.Logger.Error(
+			"error in reading response body",
+			zap.String("node", node.Address()),
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error in reading response body")
+		c.JSON(http.StatusInternalServerError, ErrInternal)
+		return nil, false
+	}
+
+	c.SetResponseHeader("Content-Type", res.Header.Get("Content-Type"))
+	c.Byte(res.StatusCode, bytes)
+
+	return bytes, res.StatusCode == http.StatusOK
+}
+
 // GetFromCache gets a key from cache.
 func (s Server) GetFromCache(c *kid.Context) {
 	ctx, span := getSpan(c, "get_from_cache")
@@ -94,42 +421,49 @@ func (s Server) GetFromCache(c *kid.Context) {
 	switch isLocal {
 	// Is local node.
 	case true:
-		val, err := s.cache.Get(key)
+		res, err := s.getLocal(ctx, key)
 		if err != nil {
-			if err == cache.ErrNotFound {
-				c.JSON(http.StatusNotFound, ErrNotFound)
-				span.SetAttributes(attribute.Bool("key_found", false))
-				return
-			}
-			app.App.Logger.Error("error in getting key from cache", zap.Error(err))
-			span.RecordError(err)
-			span.SetStatus(codes.Error, "error in getting key from cache")
-			c.JSON(http.StatusInternalServerError, ErrInternal)
+			cacheError(c, span, err, "error in getting key from cache")
 			return
 		}
-		span.SetAttributes(attribute.Bool("key_found", true))
 
-		res := GetResponse{Value: val}
 		c.JSON(http.StatusOK, &res)
 
 	// Is not local node.
 	case false:
+		if s.redirect(c, span, node) {
+			return
+		}
+
+		if node.IsDown() {
+			peerError(c, span, node, "/get", cluster.ErrNodeDown)
+			return
+		}
+
 		app.App.Logger.Debug("getting key from another node", zap.String("node", node.Address()))
 
-		req, _ := http.NewRequest(http.MethodGet, s.mergeAddressAndPath(node.Address(), "/get")+"?key="+key, nil)
+		if address := node.RPCAddress(); address != "" {
+			res, err := s.rpc.Get(ctx, address, key)
+			if err != nil {
+				s.rpcError(c, span, node, "/get", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, &GetResponse{
+				Value:     json.RawMessage(res.Value),
+				Stale:     res.Stale,
+				Version:   res.Version,
+				Timestamp: res.Timestamp,
+			})
+			return
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, s.mergeAddressAndPath(node.Address(), "/get")+"?key="+url.QueryEscape(key), nil)
 		req = injectReq(ctx, req)
 
-		res, err := http.DefaultClient.Do(req)
+		res, err := s.peers.Do(req)
 		if err != nil {
-			app.App.Logger.Error(
-				"error in calling a cluster member",
-				zap.String("node", node.Address()),
-				zap.String("path", "/get"),
-				zap.Error(err),
-			)
-			span.RecordError(err)
-			span.SetStatus(codes.Error, "error in calling a cluster member")
-			c.JSON(http.StatusInternalServerError, ErrInternal)
+			peerError(c, span, node, "/get", err)
 			return
 		}
 		defer res.Body.Close()
@@ -172,6 +506,31 @@ func (s Server) SetToCache(c *kid.Context) {
 		return
 	}
 
+	if req.SoftTTL < 0 || req.HardTTL < 0 || (req.HardTTL > 0 && req.SoftTTL > req.HardTTL) {
+		span.RecordError(ErrTTL)
+		c.JSON(http.StatusBadRequest, ErrInvalidTTL)
+		return
+	}
+
+	var ts hlc.Timestamp
+	var err error
+	if header := c.GetRequestHeader(TimestampHeader); header != "" {
+		if s.cluster.IsMember(ctx, c.Request().RemoteAddr) {
+			err = ts.UnmarshalText([]byte(header))
+		} else {
+			span.SetAttributes(attribute.Bool("timestamp_ignored", true))
+		}
+	}
+	if err == nil {
+		req.Timestamp, err = s.stamp(ts)
+	}
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+	span.SetAttributes(attribute.Stringer("timestamp", req.Timestamp))
+
 	node := s.cluster.GetNodeFromKey(req.Key)
 	isLocal := node.IsLocal()
 
@@ -180,11 +539,15 @@ func (s Server) SetToCache(c *kid.Context) {
 	switch isLocal {
 	// Is local node.
 	case true:
-		if err := s.cache.Set(req.Key, req.Value); err != nil {
-			app.App.Logger.Error("error in setting key to the cache", zap.Error(err))
+		switch err := s.setLocal(ctx, req.Key, req.Value, req.SoftTTL, req.HardTTL, req.Timestamp); {
+		case err == writebehind.ErrQueueFull:
+			app.App.Logger.Warn("error in queueing key for write-behind", zap.String("key", req.Key), zap.Error(err))
 			span.RecordError(err)
-			span.SetStatus(codes.Error, "error in setting key to the cache")
-			c.JSON(http.StatusInternalServerError, ErrInternal)
+			span.SetStatus(codes.Error, "error in queueing key for write-behind")
+			c.JSON(http.StatusServiceUnavailable, ErrWriteBehindFull)
+			return
+		case err != nil:
+			cacheError(c, span, err, "error in setting key to the cache")
 			return
 		}
 
@@ -193,28 +556,52 @@ func (s Server) SetToCache(c *kid.Context) {
 
 	// Is not local node.
 	case false:
+		if s.redirect(c, span, node) {
+			return
+		}
+
+		if node.IsDown() {
+			if !s.handOff(ctx, c, span, node, req, cluster.ErrNodeDown) {
+				peerError(c, span, node, "/set", cluster.ErrNodeDown)
+			}
+			return
+		}
+
 		app.App.Logger.Debug("setting key to another node", zap.String("node", node.Address()))
 
+		if address := node.RPCAddress(); address != "" {
+			if err := s.rpc.Set(ctx, address, req.Key, req.Value, req.SoftTTL, req.HardTTL, req.Timestamp); err != nil {
+				if !s.handOff(ctx, c, span, node, req, err) {
+					s.rpcError(c, span, node, "/set", err)
+				}
+				return
+			}
+			s.hints.Drop(node.Index(), req.Key)
+
+			c.SetResponseHeader("Content-Type", "application/json")
+			c.Byte(http.StatusOK, EmptyResponse)
+			return
+		}
+
 		jsonBytes, _ := json.Marshal(&req)
 
-		req, _ := http.NewRequest(http.MethodPost, s.mergeAddressAndPath(node.Address(), "/set"), bytes.NewBuffer(jsonBytes))
-		req = injectReq(ctx, req)
+		httpReq, _ := http.NewRequest(http.MethodPost, s.mergeAddressAndPath(node.Address(), "/set"), bytes.NewBuffer(jsonBytes))
+		httpReq.Header.Set(TimestampHeader, req.Timestamp.String())
+		httpReq = injectReq(peer.Idempotent(ctx), httpReq)
 
-		res, err := http.DefaultClient.Do(req)
+		res, err := s.peers.Do(httpReq)
 		if err != nil {
-			app.App.Logger.Error(
-				"error in calling a cluster member",
-				zap.String("node", node.Address()),
-				zap.String("path", "/set"),
-				zap.Error(err),
-			)
-			span.RecordError(err)
-			span.SetStatus(codes.Error, "error in calling a cluster member")
-			c.JSON(http.StatusInternalServerError, ErrInternal)
+			if !s.handOff(ctx, c, span, node, req, err) {
+				peerError(c, span, node, "/set", err)
+			}
 			return
 		}
 		defer res.Body.Close()
 
+		if res.StatusCode == http.StatusOK {
+			s.hints.Drop(node.Index(), req.Key)
+		}
+
 		bytes, err := io.ReadAll(res.Body)
 		if err != nil {
 			app.App.Logger.Error(
@@ -234,6 +621,67 @@ func (s Server) SetToCache(c *kid.Context) {
 	}
 }
 
+// DeleteFromCache deletes a key from cache.
+func (s Server) DeleteFromCache(c *kid.Context) {
+	ctx, span := getSpan(c, "delete_from_cache")
+	defer span.End()
+
+	var req DeleteRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	node := s.cluster.GetNodeFromKey(req.Key)
+	if !node.IsLocal() {
+		// A pending hint of the key would bring it back.
+		s.hints.Drop(node.Index(), req.Key)
+	}
+
+	if !node.IsLocal() && node.RPCAddress() != "" {
+		span.SetAttributes(attribute.Bool("is_local", false))
+		if s.redirect(c, span, node) {
+			return
+		}
+
+		if node.IsDown() {
+			peerError(c, span, node, "/delete", cluster.ErrNodeDown)
+			return
+		}
+
+		if err := s.rpc.Delete(ctx, node.RPCAddress(), req.Key); err != nil {
+			s.rpcError(c, span, node, "/delete", err)
+			return
+		}
+
+		c.SetResponseHeader("Content-Type", "application/json")
+		c.Byte(http.StatusOK, EmptyResponse)
+		return
+	}
+
+	if !s.routeTo(peer.Idempotent(ctx), c, span, node, body) {
+		return
+	}
+
+	if err := s.cache.Delete(req.Key); err != nil {
+		cacheError(c, span, err, "error in deleting key from cache")
+		return
+	}
+	span.SetAttributes(attribute.Bool("key_found", true))
+
+	c.SetResponseHeader("Content-Type", "application/json")
+	c.Byte(http.StatusOK, EmptyResponse)
+}
+
 // FlushCache clears cache.
 func (s Server) FlushCache(c *kid.Context) {
 	ctx, span := getSpan(c, "get_from_cache")
@@ -252,27 +700,7 @@ func (s Server) FlushCache(c *kid.Context) {
 	if flushAll {
 		app.App.Logger.Debug("flushing other nodes")
 
-		nodes := s.cluster.NonLocalNodes()
-		var wg sync.WaitGroup
-		var i int
-		errs := make([]error, len(nodes))
-
-		wg.Add(len(nodes))
-		for _, node := range nodes {
-			go func(i int, node cluster.Node) {
-				defer wg.Done()
-
-				req, _ := http.NewRequest(http.MethodGet, s.mergeAddressAndPath(node.Address(), "/flush?all=false"), nil)
-				req = injectReq(ctx, req)
-
-				_, err := http.DefaultClient.Do(req)
-				errs[i] = err
-			}(i, node)
-			i++
-		}
-		wg.Wait()
-
-		if err := errors.Join(errs...); err != nil {
+		if err := s.flushNodes(ctx); err != nil {
 			app.App.Logger.Error(
 				"flushing the cache of some nodes failed",
 				zap.Error(err),
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/config"
+	"github.com/mojixcoder/caster/internal/hlc"
+)
+
+func TestSetToCacheTimestampHeader(t *testing.T) {
+	s := newTestServer(t,
+		config.NodeConfig{Index: 0, Address: "http://127.0.0.1:7000", IsLocal: true},
+		config.NodeConfig{Index: 1, Address: "http://10.0.0.2:7000"},
+	)
+
+	key := ownedKey(t, s, "key", 0)
+
+	set := func(remoteAddr string, val any, ts hlc.Timestamp) int {
+		req := newRequest(t, http.MethodPost, "/set", map[string]any{"key": key, "value": val})
+		req.RemoteAddr = remoteAddr
+		if ts != 0 {
+			req.Header.Set(TimestampHeader, ts.String())
+		}
+		return serve(t, s, req, nil)
+	}
+
+	get := func() GetResponse {
+		var res GetResponse
+		if code := serve(t, s, newRequest(t, http.MethodGet, "/get?key="+key, nil), &res); code != http.StatusOK {
+			t.Fatalf("got status %d from get", code)
+		}
+		return res
+	}
+
+	// A client can't make the key reject later writes by sending a timestamp ahead of the clock.
+	ahead := hlc.NewTimestamp(time.Now().Add(30*time.Second), 0)
+	if code := set("192.0.2.1:1234", "client", ahead); code != http.StatusOK {
+		t.Fatalf("got status %d from a client write", code)
+	}
+	if res := get(); res.Timestamp >= ahead {
+		t.Fatalf("got timestamp %s, want the one of the client ignored", res.Timestamp)
+	}
+	if code := set("192.0.2.1:1234", "next", 0); code != http.StatusOK {
+		t.Fatalf("got status %d from a write after the client's timestamp", code)
+	}
+
+	// Nor can it replay the key's timestamp to have its write dropped as a retry.
+	last := get().Timestamp
+	if code := set("192.0.2.1:1234", "replayed", last); code != http.StatusOK {
+		t.Fatalf("got status %d from a replayed timestamp", code)
+	}
+	if res := get(); res.Value != "replayed" || res.Timestamp == last {
+		t.Fatalf("got %v with timestamp %s, want the write applied with a new timestamp", res.Value, res.Timestamp)
+	}
+
+	// The timestamps of other nodes are kept.
+	ts := s.clock.Now() + 1
+	if code := set("10.0.0.2:5555", "node", ts); code != http.StatusOK {
+		t.Fatalf("got status %d from a node write", code)
+	}
+	if res := get(); res.Value != "node" || res.Timestamp != ts {
+		t.Fatalf("got %v with timestamp %s, want %q with %s", res.Value, res.Timestamp, "node", ts)
+	}
+	if code := set("10.0.0.2:5555", "older", ts-1); code != http.StatusConflict {
+		t.Fatalf("got status %d from an older node write, want %d", code, http.StatusConflict)
+	}
+}
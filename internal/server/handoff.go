@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/handoff"
+	"github.com/mojixcoder/caster/internal/peer"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// HintedResponse is the response of a write which is kept as a hint, because the node which owns the key is unreachable.
+// The write is delivered to the node once it's reachable again.
+var HintedResponse = []byte("{\"message\":\"hinted\"}\n")
+
+// unreachable determines if a call to another node failed because the node couldn't be reached,
+// as opposed to the node rejecting the call.
+func unreachable(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, peer.ErrCircuitOpen) ||
+		errors.Is(err, cluster.ErrNodeDown) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// handOff keeps a write to an unreachable node as a hint, if hinted handoff is enabled.
+// It returns true if the write is hinted and the response is written.
+func (s Server) handOff(ctx context.Context, c *kid.Context, span tracesdk.Span, node cluster.Node, req SetRequest, err error) bool {
+	if !s.hint(ctx, span, node, req, err) {
+		return false
+	}
+
+	c.SetResponseHeader("Content-Type", "application/json")
+	c.Byte(http.StatusAccepted, HintedResponse)
+
+	return true
+}
+
+// hint keeps a write which failed with err as a hint, if hinted handoff is enabled and the node is unreachable.
+// It returns true if the write is hinted, the caller reports it to the client.
+func (s Server) hint(ctx context.Context, span tracesdk.Span, node cluster.Node, req SetRequest, err error) bool {
+	if !s.hints.Enabled() || ctx.Err() != nil || !unreachable(err) {
+		return false
+	}
+
+	hint := handoff.Hint{
+		Node:      node.Index(),
+		Key:       req.Key,
+		Value:     req.Value,
+		SoftTTL:   req.SoftTTL,
+		HardTTL:   req.HardTTL,
+		Timestamp: req.Timestamp,
+		Created:   time.Now(),
+	}
+	if err := s.hints.Add(hint); err != nil {
+		app.App.Logger.Warn("error in keeping write as a hint", zap.String("node", node.Address()), zap.Error(err))
+		span.RecordError(err)
+		return false
+	}
+
+	app.App.Logger.Debug(
+		"kept write to unreachable node as a hint",
+		zap.String("node", node.Address()),
+		zap.String("key", req.Key),
+		zap.Error(err),
+	)
+	span.RecordError(err)
+	span.SetAttributes(attribute.Bool("hinted", true))
+
+	return true
+}
+
+// deliverHint delivers a hint to the node which owns its key.
+// Hints which the node rejects are dropped, retrying them can't help.
+// Hints older than the key's last write on the node are expected, the key has been written since the hint.
+func (s Server) deliverHint(ctx context.Context, h handoff.Hint) error {
+	err := s.setTo(ctx, s.cluster.GetNodeFromKey(h.Key), h.Key, h.Value, h.SoftTTL, h.HardTTL, h.Timestamp)
+	if err == cache.ErrOutdated {
+		app.App.Logger.Debug("dropped outdated hint", zap.Int("node", h.Node), zap.String("key", h.Key))
+		return nil
+	}
+	if err != nil && !unreachable(err) {
+		app.App.Logger.Warn("dropped hint rejected by its node", zap.Int("node", h.Node), zap.String("key", h.Key), zap.Error(err))
+		return nil
+	}
+
+	return err
+}
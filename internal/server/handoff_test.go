@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/config"
+	"github.com/mojixcoder/caster/internal/handoff"
+)
+
+func TestWritesOutdateOlderHints(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	src := LoadScriptRequest{Source: "def main(keys, args):\n    cache.set(keys[0], args[0])\n"}
+	var loaded LoadScriptResponse
+	if code := serve(t, s, newRequest(t, http.MethodPost, "/scripts/load?local=true", &src), &loaded); code != http.StatusOK {
+		t.Fatalf("got status %d from loading the script", code)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		in     any
+	}{
+		{name: "typed write", target: "/hset", in: &HSetRequest{Key: "hash", Fields: map[string]any{"field": "value"}}},
+		{name: "increment", target: "/hincrby", in: &HIncrByRequest{Key: "hash", Field: "count", Increment: 1}},
+		{name: "script", target: "/scripts/run", in: &RunScriptRequest{ID: loaded.ID, Keys: []string{"script"}, Args: []any{"value"}}},
+		{name: "transaction", target: "/tx", in: map[string]any{"ops": []any{map[string]any{"op": TxOpSet, "key": "tx", "value": "value"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The hint is created before the write, but it's delivered after it.
+			hint := s.clock.Now()
+
+			if code := serve(t, s, newRequest(t, http.MethodPost, tt.target, tt.in), nil); code != http.StatusOK {
+				t.Fatalf("got status %d", code)
+			}
+
+			key := map[string]string{"/hset": "hash", "/hincrby": "hash", "/scripts/run": "script", "/tx": "tx"}[tt.target]
+			if err := s.setLocal(ctx, key, json.RawMessage(`"hint"`), 0, 0, hint); err != cache.ErrOutdated {
+				t.Fatalf("got %v from delivering an older hint, want %v", err, cache.ErrOutdated)
+			}
+		})
+	}
+}
+
+func TestForwardedWritesDropHints(t *testing.T) {
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/scripts/run" {
+			var req RunScriptRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(&RunScriptResponse{Changed: req.Keys[:1]})
+			return
+		}
+		w.Write(EmptyResponse)
+	}))
+	defer node.Close()
+
+	s := newTestServer(t,
+		config.NodeConfig{Index: 0, Address: "http://127.0.0.1:7000", IsLocal: true},
+		config.NodeConfig{Index: 1, Address: node.URL},
+	)
+
+	written, read := ownedKey(t, s, "written", 1), ownedKey(t, s, "read", 1)
+
+	tests := []struct {
+		name   string
+		target string
+		in     any
+	}{
+		{name: "transaction", target: "/tx", in: map[string]any{"ops": []any{
+			map[string]any{"op": TxOpCheck, "key": read},
+			map[string]any{"op": TxOpSet, "key": written, "value": "value"},
+		}}},
+		{name: "script", target: "/scripts/run", in: &RunScriptRequest{ID: "id", Keys: []string{written, read}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{written, read} {
+				s.hints.Add(handoff.Hint{Node: 1, Key: key, Value: json.RawMessage(`"hint"`), Created: time.Now()})
+			}
+
+			if code := serve(t, s, newRequest(t, http.MethodPost, tt.target, tt.in), nil); code != http.StatusOK {
+				t.Fatalf("got status %d", code)
+			}
+
+			// Only the hint of the written key is older than the write, the other one must still be delivered.
+			if pending := s.hints.Pending()[1]; pending != 1 {
+				t.Fatalf("got %d pending hints, want 1", pending)
+			}
+			s.hints.Drop(1, read)
+		})
+	}
+}
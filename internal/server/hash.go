@@ -0,0 +1,319 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type (
+	HSetRequest struct {
+		Key    string         `json:"key"`
+		Fields map[string]any `json:"fields"`
+	}
+
+	HSetResponse struct {
+		Added int `json:"added"`
+	}
+
+	HDelRequest struct {
+		Key    string   `json:"key"`
+		Fields []string `json:"fields"`
+	}
+
+	HDelResponse struct {
+		Deleted int `json:"deleted"`
+	}
+
+	HGetAllResponse struct {
+		Fields map[string]any `json:"fields"`
+	}
+
+	HIncrByRequest struct {
+		Key       string `json:"key"`
+		Field     string `json:"field"`
+		Increment int64  `json:"increment"`
+	}
+
+	HIncrByResponse struct {
+		Value int64 `json:"value"`
+	}
+)
+
+var (
+	ErrFieldRequired = kid.Map{"message": "field is required."}
+
+	ErrFieldsRequired = kid.Map{"message": "at least a field is required."}
+
+	ErrNoField = errors.New("field is required")
+)
+
+// asHash returns the value as a hash. A nil value is returned as a new hash if create is true.
+func asHash(val cache.Value, create bool) (*cache.Hash, error) {
+	if val == nil {
+		if create {
+			return cache.NewHash(), nil
+		}
+		return nil, cache.ErrNotFound
+	}
+
+	hash, ok := val.(*cache.Hash)
+	if !ok {
+		return nil, cache.ErrWrongType
+	}
+
+	return hash, nil
+}
+
+// HSet sets fields of a hash.
+func (s Server) HSet(c *kid.Context) {
+	ctx, span := getSpan(c, "hset")
+	defer span.End()
+
+	var req HSetRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if len(req.Fields) == 0 {
+		span.RecordError(ErrNoField)
+		c.JSON(http.StatusBadRequest, ErrFieldsRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res HSetResponse
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		hash, err := asHash(val, true)
+		if err != nil {
+			return nil, err
+		}
+
+		for field, v := range req.Fields {
+			if hash.Set(field, v) {
+				res.Added++
+			}
+		}
+
+		return hash, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in setting hash fields")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// HGet gets a field of a hash.
+func (s Server) HGet(c *kid.Context) {
+	ctx, span := getSpan(c, "hget")
+	defer span.End()
+
+	key, field := c.QueryParam("key"), c.QueryParam("field")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if field == "" {
+		span.RecordError(ErrNoField)
+		c.JSON(http.StatusBadRequest, ErrFieldRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	var res GetResponse
+	err := s.cache.View(key, func(val cache.Value) error {
+		hash, err := asHash(val, false)
+		if err != nil {
+			return err
+		}
+
+		v, ok := hash.Get(field)
+		if !ok {
+			return cache.ErrNotFound
+		}
+		res.Value = v
+
+		return nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in getting hash field")
+		return
+	}
+	span.SetAttributes(attribute.Bool("key_found", true))
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// HDel deletes fields of a hash. The hash is deleted when it has no fields left.
+func (s Server) HDel(c *kid.Context) {
+	ctx, span := getSpan(c, "hdel")
+	defer span.End()
+
+	var req HDelRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if len(req.Fields) == 0 {
+		span.RecordError(ErrNoField)
+		c.JSON(http.StatusBadRequest, ErrFieldsRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res HDelResponse
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		if val == nil {
+			return nil, nil
+		}
+
+		hash, err := asHash(val, false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, field := range req.Fields {
+			if hash.Delete(field) {
+				res.Deleted++
+			}
+		}
+
+		if hash.Len() == 0 {
+			return nil, nil
+		}
+
+		return hash, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in deleting hash fields")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// HGetAll gets all fields of a hash. A missing hash has no fields.
+func (s Server) HGetAll(c *kid.Context) {
+	ctx, span := getSpan(c, "hgetall")
+	defer span.End()
+
+	key := c.QueryParam("key")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	res := HGetAllResponse{Fields: map[string]any{}}
+	err := s.cache.View(key, func(val cache.Value) error {
+		hash, err := asHash(val, false)
+		if err != nil {
+			return err
+		}
+
+		res.Fields = hash.All()
+
+		return nil
+	})
+	if err != nil && err != cache.ErrNotFound {
+		cacheError(c, span, err, "error in getting hash fields")
+		return
+	}
+	span.SetAttributes(attribute.Bool("key_found", err == nil))
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// HIncrBy increments the integer value of a hash field.
+func (s Server) HIncrBy(c *kid.Context) {
+	ctx, span := getSpan(c, "hincrby")
+	defer span.End()
+
+	var req HIncrByRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if req.Field == "" {
+		span.RecordError(ErrNoField)
+		c.JSON(http.StatusBadRequest, ErrFieldRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res HIncrByResponse
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		hash, err := asHash(val, true)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Value, err = hash.IncrBy(req.Field, req.Increment)
+		if err != nil {
+			return nil, err
+		}
+
+		return hash, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in incrementing hash field")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/peer"
+	"github.com/mojixcoder/caster/internal/rpc"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type (
+	PFAddRequest struct {
+		Key      string   `json:"key"`
+		Elements []string `json:"elements"`
+	}
+
+	PFAddResponse struct {
+		Changed bool `json:"changed"`
+	}
+
+	PFCountResponse struct {
+		Count uint64 `json:"count"`
+	}
+
+	PFMergeRequest struct {
+		Dest    string   `json:"dest"`
+		Sources []string `json:"sources"`
+	}
+
+	PFRegistersResponse struct {
+		// Registers are the merged registers of the requested keys, nil if none of them exist.
+		Registers []byte `json:"registers"`
+	}
+)
+
+var (
+	ErrDestRequired = kid.Map{"message": "dest is required."}
+
+	ErrNoDest = errors.New("dest is required")
+)
+
+// asHyperLogLog returns the value as a HyperLogLog. A nil value is returned as a new HyperLogLog if create is true.
+func asHyperLogLog(val cache.Value, create bool) (*cache.HyperLogLog, error) {
+	if val == nil {
+		if create {
+			return cache.NewHyperLogLog(), nil
+		}
+		return nil, cache.ErrNotFound
+	}
+
+	hll, ok := val.(*cache.HyperLogLog)
+	if !ok {
+		return nil, cache.ErrWrongType
+	}
+
+	return hll, nil
+}
+
+// PFAdd adds elements to a HyperLogLog.
+func (s Server) PFAdd(c *kid.Context) {
+	ctx, span := getSpan(c, "pfadd")
+	defer span.End()
+
+	var req PFAddRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res PFAddResponse
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		hll, err := asHyperLogLog(val, true)
+		if err != nil {
+			return nil, err
+		}
+
+		// Creating an empty HyperLogLog is a change too.
+		res.Changed = hll.Add(req.Elements...) || val == nil
+
+		return hll, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in adding hyperloglog elements")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// PFCount gets the estimated number of unique elements in the union of the given HyperLogLogs.
+// The keys can live on different nodes, their registers are fetched and merged on this node.
+func (s Server) PFCount(c *kid.Context) {
+	ctx, span := getSpan(c, "pfcount")
+	defer span.End()
+
+	keys := c.QueryParamMultiple("key")
+	if len(keys) == 0 {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeysRequired)
+		return
+	}
+
+	span.SetAttributes(attribute.StringSlice("keys", keys))
+
+	// A single key is counted by its owner, so registers aren't sent over the network.
+	if len(keys) == 1 && !s.route(ctx, c, span, keys[0], nil) {
+		return
+	}
+
+	registers, err := s.collectRegisters(ctx, keys)
+	if err != nil {
+		registersError(c, span, err, "error in collecting hyperloglog registers")
+		return
+	}
+
+	c.JSON(http.StatusOK, &PFCountResponse{Count: cache.EstimateRegisters(registers)})
+}
+
+// PFMerge merges the source HyperLogLogs into dest. The sources can live on different nodes.
+func (s Server) PFMerge(c *kid.Context) {
+	ctx, span := getSpan(c, "pfmerge")
+	defer span.End()
+
+	var req PFMergeRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Dest == "" {
+		span.RecordError(ErrNoDest)
+		c.JSON(http.StatusBadRequest, ErrDestRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Dest, body) {
+		return
+	}
+
+	registers, err := s.collectRegisters(ctx, req.Sources)
+	if err != nil {
+		registersError(c, span, err, "error in collecting hyperloglog registers")
+		return
+	}
+
+	err = s.cache.Update(req.Dest, func(val cache.Value) (cache.Value, error) {
+		hll, err := asHyperLogLog(val, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := hll.Merge(registers); err != nil {
+			return nil, err
+		}
+
+		return hll, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in merging hyperloglogs")
+		return
+	}
+
+	c.SetResponseHeader("Content-Type", "application/json")
+	c.Byte(http.StatusOK, EmptyResponse)
+}
+
+// PFRegisters gets the merged registers of the given HyperLogLogs of the local node.
+// It's used by other nodes to merge HyperLogLogs which live on different nodes.
+func (s Server) PFRegisters(c *kid.Context) {
+	_, span := getSpan(c, "pfregisters")
+	defer span.End()
+
+	keys := c.QueryParamMultiple("key")
+
+	var res PFRegistersResponse
+	err := s.cache.Atomic(func(tx cache.Tx) error {
+		var err error
+		res.Registers, err = localRegisters(tx, keys)
+		return err
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in getting hyperloglog registers")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// localRegisters returns the merged registers of the given HyperLogLogs, nil if none of them exist.
+func localRegisters(tx cache.Tx, keys []string) ([]byte, error) {
+	var registers []byte
+
+	for _, key := range keys {
+		val := tx.Get(key)
+		if val == nil {
+			continue
+		}
+
+		hll, err := asHyperLogLog(val, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if registers == nil {
+			registers = hll.Registers()
+		} else if err := cache.MergeRegisters(registers, hll.Registers()); err != nil {
+			return nil, err
+		}
+	}
+
+	return registers, nil
+}
+
+// collectRegisters returns the merged registers of the given HyperLogLogs of every node.
+// Missing keys are ignored.
+func (s Server) collectRegisters(ctx context.Context, keys []string) ([]byte, error) {
+	keysByNode := make(map[cluster.Node][]string)
+	for _, key := range keys {
+		node := s.cluster.GetNodeFromKey(key)
+		keysByNode[node] = append(keysByNode[node], key)
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, 0)
+	merged := make([]byte, cache.HLLRegisters)
+
+	merge := func(registers []byte, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if err == nil && registers != nil {
+			err = cache.MergeRegisters(merged, registers)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for node, keys := range keysByNode {
+		if node.IsLocal() {
+			var registers []byte
+			err := s.cache.Atomic(func(tx cache.Tx) error {
+				var err error
+				registers, err = localRegisters(tx, keys)
+				return err
+			})
+			merge(registers, err)
+			continue
+		}
+
+		wg.Add(1)
+		go func(node cluster.Node, keys []string) {
+			defer wg.Done()
+			merge(s.fetchRegisters(ctx, node, keys))
+		}(node, keys)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		// Type errors are reported as they are, so they're returned to the client.
+		for _, err := range errs {
+			if err == cache.ErrWrongType {
+				return nil, err
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	return merged, nil
+}
+
+// fetchRegisters fetches the merged registers of the given HyperLogLogs from another node.
+func (s Server) fetchRegisters(ctx context.Context, node cluster.Node, keys []string) ([]byte, error) {
+	query := url.Values{"key": keys}
+
+	var body PFRegistersResponse
+	err := s.callNode(peer.Idempotent(ctx), node, http.MethodGet, "/pfregisters?"+query.Encode(), nil, nil, &body)
+
+	return body.Registers, err
+}
+
+// registersError writes the error of collecting registers, nodes which are unavailable are reported like forwarded requests.
+func registersError(c *kid.Context, span tracesdk.Span, err error, msg string) {
+	var rpcErr *rpc.Error
+
+	switch {
+	case errors.As(err, &rpcErr) && rpcErr.Status == rpc.StatusBadRequest:
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": rpcErr.Message})
+	case errors.Is(err, peer.ErrCircuitOpen), errors.Is(err, cluster.ErrNodeDown):
+		app.App.Logger.Warn("cluster member is unavailable", zap.String("path", "/pfregisters"))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, msg)
+		c.JSON(http.StatusServiceUnavailable, ErrNodeUnavailable)
+	default:
+		cacheError(c, span, err, msg)
+	}
+}
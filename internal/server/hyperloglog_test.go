@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/config"
+)
+
+func TestPFCountRemoteRegisters(t *testing.T) {
+	old := app.App.Config.Health
+	app.App.Config.Health = config.HealthConfig{Interval: 10 * time.Millisecond, Timeout: time.Second, DownThreshold: 1}
+	t.Cleanup(func() { app.App.Config.Health = old })
+
+	tests := []struct {
+		name      string
+		healthy   bool
+		status    int
+		want      int
+		wantCalls int64
+	}{
+		{name: "ok", healthy: true, status: http.StatusOK, want: http.StatusOK, wantCalls: 1},
+		{name: "wrong type", healthy: true, status: http.StatusBadRequest, want: http.StatusBadRequest, wantCalls: 1},
+		{name: "down", status: http.StatusOK, want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == cluster.HealthPath {
+					if !tt.healthy {
+						w.WriteHeader(http.StatusServiceUnavailable)
+					}
+					return
+				}
+
+				calls.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusOK {
+					json.NewEncoder(w).Encode(&PFRegistersResponse{})
+					return
+				}
+				w.Write([]byte(`{"message":"wrong type"}`))
+			}))
+			defer node.Close()
+
+			s := newTestServer(t,
+				config.NodeConfig{Index: 0, Address: "http://127.0.0.1:7000", IsLocal: true},
+				config.NodeConfig{Index: 1, Address: node.URL},
+			)
+			s.cluster.StartProbes()
+
+			remote := s.cluster.NonLocalNodes()[1]
+			deadline := time.Now().Add(5 * time.Second)
+			for !tt.healthy && !remote.IsDown() {
+				if time.Now().After(deadline) {
+					t.Fatal("node isn't reported down")
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			query := url.Values{"key": {ownedKey(t, s, "local", 0), ownedKey(t, s, "remote", 1)}}
+			if code := serve(t, s, newRequest(t, http.MethodGet, "/pfcount?"+query.Encode(), nil), nil); code != tt.want {
+				t.Fatalf("got status %d, want %d", code, tt.want)
+			}
+
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("node was called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/peer"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxBlockTimeout is the longest time a blocking pop waits for a value.
+	maxBlockTimeout = 5 * time.Minute
+
+	// forwardGrace is the extra time a forwarded blocking pop is given for the network.
+	forwardGrace = 5 * time.Second
+)
+
+type (
+	PushRequest struct {
+		Key    string `json:"key"`
+		Values []any  `json:"values"`
+	}
+
+	LengthResponse struct {
+		Length int `json:"length"`
+	}
+
+	PopRequest struct {
+		Key   string `json:"key"`
+		Count int    `json:"count"`
+	}
+
+	ValuesResponse struct {
+		Values []any `json:"values"`
+	}
+
+	TrimRequest struct {
+		Key   string `json:"key"`
+		Start int    `json:"start"`
+		Stop  int    `json:"stop"`
+	}
+
+	BlockingPopRequest struct {
+		Key     string `json:"key"`
+		Timeout int64  `json:"timeout_ms"`
+	}
+)
+
+var (
+	ErrValuesRequired = kid.Map{"message": "at least a value is required."}
+
+	ErrInvalidCount = kid.Map{"message": "count must not be negative."}
+
+	ErrInvalidRange = kid.Map{"message": "start and stop must be integers."}
+
+	ErrInvalidTimeout = kid.Map{"message": "timeout must not be negative."}
+
+	ErrNoValue = errors.New("value is required")
+)
+
+// asList returns the value as a list. A nil value is returned as a new list if create is true.
+func asList(val cache.Value, create bool) (*cache.List, error) {
+	if val == nil {
+		if create {
+			return cache.NewList(), nil
+		}
+		return nil, cache.ErrNotFound
+	}
+
+	list, ok := val.(*cache.List)
+	if !ok {
+		return nil, cache.ErrWrongType
+	}
+
+	return list, nil
+}
+
+// LPush pushes values to the front of a list.
+func (s Server) LPush(c *kid.Context) {
+	s.push(c, "lpush", true)
+}
+
+// RPush pushes values to the back of a list.
+func (s Server) RPush(c *kid.Context) {
+	s.push(c, "rpush", false)
+}
+
+// push pushes values to either end of a list.
+func (s Server) push(c *kid.Context, name string, front bool) {
+	ctx, span := getSpan(c, name)
+	defer span.End()
+
+	var req PushRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if len(req.Values) == 0 {
+		span.RecordError(ErrNoValue)
+		c.JSON(http.StatusBadRequest, ErrValuesRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res LengthResponse
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		list, err := asList(val, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if front {
+			list.PushFront(req.Values...)
+		} else {
+			list.PushBack(req.Values...)
+		}
+		res.Length = list.Len()
+
+		return list, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in pushing to list")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// LPop pops values from the front of a list.
+func (s Server) LPop(c *kid.Context) {
+	s.pop(c, "lpop", true)
+}
+
+// RPop pops values from the back of a list.
+func (s Server) RPop(c *kid.Context) {
+	s.pop(c, "rpop", false)
+}
+
+// pop pops values from either end of a list.
+func (s Server) pop(c *kid.Context, name string, front bool) {
+	ctx, span := getSpan(c, name)
+	defer span.End()
+
+	var req PopRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if req.Count < 0 {
+		c.JSON(http.StatusBadRequest, ErrInvalidCount)
+		return
+	}
+
+	if req.Count == 0 {
+		req.Count = 1
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	vals, err := s.popLocal(req.Key, req.Count, front)
+	if err != nil {
+		cacheError(c, span, err, "error in popping from list")
+		return
+	}
+
+	c.JSON(http.StatusOK, &ValuesResponse{Values: vals})
+}
+
+// popLocal pops values from a list of the local cache. The list is deleted when it's empty.
+func (s Server) popLocal(key string, count int, front bool) ([]any, error) {
+	var vals []any
+	err := s.cache.Update(key, func(val cache.Value) (cache.Value, error) {
+		list, err := asList(val, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if front {
+			vals = list.PopFront(count)
+		} else {
+			vals = list.PopBack(count)
+		}
+
+		if list.Len() == 0 {
+			return nil, nil
+		}
+
+		return list, nil
+	})
+
+	return vals, err
+}
+
+// LRange gets the values between start and stop of a list, both inclusive.
+func (s Server) LRange(c *kid.Context) {
+	ctx, span := getSpan(c, "lrange")
+	defer span.End()
+
+	key := c.QueryParam("key")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	start, err := queryInt(c, "start", 0)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, ErrInvalidRange)
+		return
+	}
+
+	stop, err := queryInt(c, "stop", -1)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, ErrInvalidRange)
+		return
+	}
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	res := ValuesResponse{Values: []any{}}
+	err = s.cache.View(key, func(val cache.Value) error {
+		list, err := asList(val, false)
+		if err != nil {
+			return err
+		}
+
+		res.Values = list.Range(start, stop)
+
+		return nil
+	})
+	if err != nil && err != cache.ErrNotFound {
+		cacheError(c, span, err, "error in getting list range")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// LTrim trims a list to the values between start and stop, both inclusive.
+func (s Server) LTrim(c *kid.Context) {
+	ctx, span := getSpan(c, "ltrim")
+	defer span.End()
+
+	var req TrimRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		if val == nil {
+			return nil, nil
+		}
+
+		list, err := asList(val, false)
+		if err != nil {
+			return nil, err
+		}
+
+		list.Trim(req.Start, req.Stop)
+		if list.Len() == 0 {
+			return nil, nil
+		}
+
+		return list, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in trimming list")
+		return
+	}
+
+	c.SetResponseHeader("Content-Type", "application/json")
+	c.Byte(http.StatusOK, EmptyResponse)
+}
+
+// LLen gets the length of a list. A missing list is empty.
+func (s Server) LLen(c *kid.Context) {
+	ctx, span := getSpan(c, "llen")
+	defer span.End()
+
+	key := c.QueryParam("key")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	var res LengthResponse
+	err := s.cache.View(key, func(val cache.Value) error {
+		list, err := asList(val, false)
+		if err != nil {
+			return err
+		}
+
+		res.Length = list.Len()
+
+		return nil
+	})
+	if err != nil && err != cache.ErrNotFound {
+		cacheError(c, span, err, "error in getting list length")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// BLPop pops a value from the front of a list, waiting for one if the list is empty.
+func (s Server) BLPop(c *kid.Context) {
+	s.blockingPop(c, "blpop", true)
+}
+
+// BRPop pops a value from the back of a list, waiting for one if the list is empty.
+func (s Server) BRPop(c *kid.Context) {
+	s.blockingPop(c, "brpop", false)
+}
+
+// blockingPop pops a value from either end of a list.
+// If the list is empty, it long-polls until a value is pushed or the timeout is reached.
+// A zero timeout waits as long as possible.
+func (s Server) blockingPop(c *kid.Context, name string, front bool) {
+	ctx, span := getSpan(c, name)
+	defer span.End()
+
+	var req BlockingPopRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if req.Timeout < 0 {
+		c.JSON(http.StatusBadRequest, ErrInvalidTimeout)
+		return
+	}
+
+	timeout := time.Duration(req.Timeout) * time.Millisecond
+	if timeout == 0 || timeout > maxBlockTimeout {
+		timeout = maxBlockTimeout
+	}
+
+	// The owner may wait as long as the timeout, so the forwarded request is a long poll which the peer timeouts don't limit.
+	routeCtx, cancel := context.WithTimeout(peer.LongPoll(ctx), timeout+forwardGrace)
+	defer cancel()
+
+	if !s.route(routeCtx, c, span, req.Key, body) {
+		return
+	}
+
+	// Subscribing before the first attempt, so a push between the attempt and waiting isn't missed.
+	sub := s.events.Subscribe(1, func(e cache.Event) bool {
+		return e.Op == cache.OpSet && e.Key == req.Key
+	})
+	defer s.events.Unsubscribe(sub)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var waited bool
+	for {
+		vals, err := s.popLocal(req.Key, 1, front)
+		if err != nil && err != cache.ErrNotFound {
+			cacheError(c, span, err, "error in popping from list")
+			return
+		}
+
+		if len(vals) > 0 {
+			span.SetAttributes(attribute.Bool("waited", waited))
+			c.JSON(http.StatusOK, &GetResponse{Value: vals[0]})
+			return
+		}
+
+		waited = true
+		select {
+		case <-sub.C:
+		case <-timer.C:
+			span.SetAttributes(attribute.Bool("timed_out", true))
+			c.JSON(http.StatusNotFound, ErrNotFound)
+			return
+		case <-c.Request().Context().Done():
+			return
+		}
+	}
+}
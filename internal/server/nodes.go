@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"github.com/mojixcoder/caster/internal/peer"
+	"github.com/mojixcoder/caster/internal/rpc"
+)
+
+// The functions below perform basic operations on the node which owns a key, whether it's the local node or not.
+// Other nodes are called over RPC if they have an RPC address, otherwise over HTTP.
+// Calls to nodes which health probes report down fail fast with cluster.ErrNodeDown.
+// Values are JSON encoded, so they're decoded only by the node which owns the key.
+
+// getFrom gets a key from the node.
+func (s Server) getFrom(ctx context.Context, node cluster.Node, key string) (rpc.GetResult, error) {
+	if node.IsLocal() {
+		res, err := s.getLocal(ctx, key)
+		if err != nil {
+			return rpc.GetResult{}, err
+		}
+
+		val, err := json.Marshal(res.Value)
+		if err != nil {
+			return rpc.GetResult{}, err
+		}
+
+		return rpc.GetResult{Value: val, Stale: res.Stale, Version: res.Version, Timestamp: res.Timestamp}, nil
+	}
+
+	if node.IsDown() {
+		return rpc.GetResult{}, cluster.ErrNodeDown
+	}
+
+	if address := node.RPCAddress(); address != "" {
+		return s.rpc.Get(ctx, address, key)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, s.mergeAddressAndPath(node.Address(), "/get")+"?key="+url.QueryEscape(key), nil)
+	req = injectReq(ctx, req)
+
+	res, err := s.peers.Do(req)
+	if err != nil {
+		return rpc.GetResult{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return rpc.GetResult{}, statusError(res)
+	}
+
+	var body struct {
+		Value     json.RawMessage `json:"value"`
+		Stale     bool            `json:"stale"`
+		Version   uint64          `json:"version"`
+		Timestamp hlc.Timestamp   `json:"timestamp"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return rpc.GetResult{}, err
+	}
+
+	return rpc.GetResult{Value: body.Value, Stale: body.Stale, Version: body.Version, Timestamp: body.Timestamp}, nil
+}
+
+// setTo sets a key on the node, TTLs are in milliseconds and ts is the timestamp of the write.
+func (s Server) setTo(ctx context.Context, node cluster.Node, key string, val json.RawMessage, softTTL, hardTTL int64, ts hlc.Timestamp) error {
+	if node.IsLocal() {
+		return s.setLocal(ctx, key, val, softTTL, hardTTL, ts)
+	}
+
+	if node.IsDown() {
+		return cluster.ErrNodeDown
+	}
+
+	if address := node.RPCAddress(); address != "" {
+		return s.rpc.Set(ctx, address, key, val, softTTL, hardTTL, ts)
+	}
+
+	body, _ := json.Marshal(&SetRequest{Key: key, Value: val, SoftTTL: softTTL, HardTTL: hardTTL})
+	return s.callNode(peer.Idempotent(ctx), node, http.MethodPost, "/set", body, http.Header{TimestampHeader: {ts.String()}}, nil)
+}
+
+// deleteFrom deletes a key from the node.
+func (s Server) deleteFrom(ctx context.Context, node cluster.Node, key string) error {
+	if node.IsLocal() {
+		return s.cache.Delete(key)
+	}
+
+	if node.IsDown() {
+		return cluster.ErrNodeDown
+	}
+
+	if address := node.RPCAddress(); address != "" {
+		return s.rpc.Delete(ctx, address, key)
+	}
+
+	body, _ := json.Marshal(&DeleteRequest{Key: key})
+	return s.callNode(peer.Idempotent(ctx), node, http.MethodPost, "/delete", body, nil, nil)
+}
+
+// flushNodes flushes the caches of every non-local node concurrently.
+func (s Server) flushNodes(ctx context.Context) error {
+	nodes := s.cluster.NonLocalNodes()
+	var wg sync.WaitGroup
+	var i int
+	errs := make([]error, len(nodes))
+
+	wg.Add(len(nodes))
+	for _, node := range nodes {
+		go func(i int, node cluster.Node) {
+			defer wg.Done()
+
+			if address := node.RPCAddress(); address != "" {
+				errs[i] = s.rpc.Flush(ctx, address)
+				return
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, s.mergeAddressAndPath(node.Address(), "/flush?all=false"), nil)
+			req = injectReq(ctx, req)
+
+			res, err := s.peers.Do(req)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			res.Body.Close()
+		}(i, node)
+		i++
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// callNode calls the path of another node over HTTP with the body and the extra headers, and decodes the response into out unless it's nil.
+// Nodes which are down aren't called.
+func (s Server) callNode(ctx context.Context, node cluster.Node, method, path string, body []byte, header http.Header, out any) error {
+	if node.IsDown() {
+		return cluster.ErrNodeDown
+	}
+
+	req, _ := http.NewRequest(method, s.mergeAddressAndPath(node.Address(), path), bytes.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req = injectReq(ctx, req)
+
+	res, err := s.peers.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return statusError(res)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// statusError returns the error of a failed HTTP response of another node.
+func statusError(res *http.Response) error {
+	var body struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(res.Body).Decode(&body)
+
+	switch res.StatusCode {
+	case http.StatusNotFound:
+		return cache.ErrNotFound
+	case http.StatusRequestEntityTooLarge:
+		return cache.ErrTooLarge
+	case http.StatusConflict:
+		return cache.ErrOutdated
+	case http.StatusBadRequest:
+		return &rpc.Error{Status: rpc.StatusBadRequest, Message: body.Message}
+	default:
+		return fmt.Errorf("cluster member responded with status %d: %s", res.StatusCode, body.Message)
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+)
+
+// Message is a message published to a channel.
+type Message struct {
+	Channel string `json:"channel"`
+	Message any    `json:"message"`
+}
+
+var (
+	ErrChannelRequired = kid.Map{"message": "channel is required."}
+
+	ErrNoChannel = errors.New("channel is required")
+)
+
+// Publish publishes a message to a channel.
+// The message is delivered to the subscribers of every node unless local is true.
+func (s Server) Publish(c *kid.Context) {
+	ctx, span := getSpan(c, "publish")
+	defer span.End()
+
+	var msg Message
+	body, err := readJSON(c, &msg)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if msg.Channel == "" {
+		span.RecordError(ErrNoChannel)
+		c.JSON(http.StatusBadRequest, ErrChannelRequired)
+		return
+	}
+
+	local, _ := strconv.ParseBool(c.QueryParam("local"))
+
+	span.SetAttributes(attribute.String("channel", msg.Channel), attribute.Bool("local", local))
+
+	s.channels.Publish(msg)
+
+	if !local {
+		app.App.Logger.Debug("publishing message to other nodes", zap.String("channel", msg.Channel))
+
+		if err := s.broadcast(ctx, "/channels/publish?local=true", body); err != nil {
+			app.App.Logger.Error(
+				"publishing the message to some nodes failed",
+				zap.Error(err),
+				zap.String("path", "/channels/publish"),
+			)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "publishing the message to some nodes failed")
+			c.JSON(http.StatusInternalServerError, ErrInternal)
+			return
+		}
+	}
+
+	c.SetResponseHeader("Content-Type", "application/json")
+	c.Byte(http.StatusOK, EmptyResponse)
+}
+
+// SubscribeChannels streams the messages published to the given channels as server-sent events.
+// Messages published on other nodes are pushed to this node, so only local subscribers are needed.
+func (s Server) SubscribeChannels(c *kid.Context) {
+	_, span := getSpan(c, "subscribe_channels")
+	defer span.End()
+
+	channels := c.QueryParamMultiple("channel")
+	if len(channels) == 0 {
+		span.RecordError(ErrNoChannel)
+		c.JSON(http.StatusBadRequest, ErrChannelRequired)
+		return
+	}
+
+	span.SetAttributes(attribute.StringSlice("channels", channels))
+
+	wanted := make(map[string]struct{}, len(channels))
+	for _, channel := range channels {
+		wanted[channel] = struct{}{}
+	}
+
+	sub := s.channels.Subscribe(streamBufferSize, func(msg Message) bool {
+		_, ok := wanted[msg.Channel]
+		return ok
+	})
+	defer s.channels.Unsubscribe(sub)
+
+	reqCtx := c.Request().Context()
+	out := make(chan []byte, streamBufferSize)
+
+	go func() {
+		for msg := range sub.C {
+			data, _ := json.Marshal(msg)
+			select {
+			case out <- data:
+			case <-reqCtx.Done():
+				return
+			}
+		}
+	}()
+
+	stream(c, out)
+}
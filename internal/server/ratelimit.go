@@ -0,0 +1,151 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// AlgorithmTokenBucket refills the limit evenly during the window and allows bursts of up to the limit.
+	AlgorithmTokenBucket = "token_bucket"
+
+	// AlgorithmSlidingWindow allows the limit in any window.
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+type (
+	RateLimitRequest struct {
+		Key string `json:"key"`
+		// Algorithm is either token_bucket or sliding_window, token_bucket by default.
+		Algorithm string `json:"algorithm"`
+		Limit     int64  `json:"limit"`
+		Window    int64  `json:"window_ms"`
+		// Cost is 1 by default, zero only checks the limiter.
+		Cost *int64 `json:"cost"`
+	}
+
+	RateLimitResponse struct {
+		Allowed    bool  `json:"allowed"`
+		Remaining  int64 `json:"remaining"`
+		RetryAfter int64 `json:"retry_after_ms"`
+	}
+)
+
+var ErrInvalidAlgorithm = kid.Map{"message": "algorithm must be either token_bucket or sliding_window."}
+
+// newRateLimiter returns a new rate limiter of the algorithm, nil if the algorithm is unknown.
+func newRateLimiter(algorithm string) cache.RateLimiter {
+	switch algorithm {
+	case AlgorithmTokenBucket:
+		return cache.NewTokenBucket()
+	case AlgorithmSlidingWindow:
+		return cache.NewSlidingWindow()
+	default:
+		return nil
+	}
+}
+
+// idleTTL returns how long after its last take a rate limiter of the algorithm is back to its initial state.
+// A token bucket refills in a window, a sliding window needs another window for its previous window's cost to drop.
+func idleTTL(algorithm string, window time.Duration) time.Duration {
+	if algorithm == AlgorithmSlidingWindow {
+		if window > math.MaxInt64/2 {
+			return math.MaxInt64
+		}
+		return 2 * window
+	}
+	return window
+}
+
+// RateLimit spends cost from a rate limiter if it's allowed. It's evaluated atomically on the key's owner.
+func (s Server) RateLimit(c *kid.Context) {
+	ctx, span := getSpan(c, "ratelimit")
+	defer span.End()
+
+	var req RateLimitRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if req.Algorithm == "" {
+		req.Algorithm = AlgorithmTokenBucket
+	}
+
+	limiter := newRateLimiter(req.Algorithm)
+	if limiter == nil {
+		c.JSON(http.StatusBadRequest, ErrInvalidAlgorithm)
+		return
+	}
+
+	cost := int64(1)
+	if req.Cost != nil {
+		cost = *req.Cost
+	}
+
+	// A window which overflows a duration is left zero, so it's rejected like other invalid windows.
+	var window time.Duration
+	if req.Window <= math.MaxInt64/int64(time.Millisecond) {
+		window = time.Duration(req.Window) * time.Millisecond
+	}
+
+	if err := cache.ValidateRateLimit(req.Limit, window, cost); err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res cache.RateLimitResult
+	err = s.cache.Atomic(func(tx cache.Tx) error {
+		item, ok := tx.GetItem(req.Key)
+		if ok {
+			if item.Value.Kind() != limiter.Kind() {
+				return cache.ErrWrongType
+			}
+			limiter = item.Value.(cache.RateLimiter)
+		}
+
+		now := time.Now()
+		res = limiter.Take(now, req.Limit, window, cost)
+
+		// An idle limiter expires once it's back to its initial state, so limiters of keys which aren't used anymore don't pile up.
+		item.Value = limiter
+		item.Timestamp = s.clock.Now()
+		item.HardExpiry = now.Add(idleTTL(req.Algorithm, window))
+		item.SoftExpiry = item.HardExpiry
+
+		return tx.SetItem(req.Key, item)
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in taking from rate limiter")
+		return
+	}
+
+	span.SetAttributes(attribute.Bool("allowed", res.Allowed))
+
+	c.JSON(http.StatusOK, &RateLimitResponse{
+		Allowed:    res.Allowed,
+		Remaining:  res.Remaining,
+		RetryAfter: int64((res.RetryAfter + time.Millisecond - 1) / time.Millisecond),
+	})
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitWindow(t *testing.T) {
+	maxWindow := int64(math.MaxInt64 / int64(time.Millisecond))
+
+	tests := []struct {
+		name      string
+		algorithm string
+		window    int64
+		code      int
+	}{
+		{name: "valid", window: 1000, code: http.StatusOK},
+		{name: "zero", window: 0, code: http.StatusBadRequest},
+		{name: "negative", window: -1, code: http.StatusBadRequest},
+		{name: "max", window: maxWindow, code: http.StatusOK},
+		{name: "max sliding window", algorithm: AlgorithmSlidingWindow, window: maxWindow, code: http.StatusOK},
+		{name: "overflow", window: maxWindow + 1, code: http.StatusBadRequest},
+		// It wraps around to a positive duration of about a millisecond.
+		{name: "overflow to positive", window: 18446744073710, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+
+			req := map[string]any{"key": "key", "algorithm": tt.algorithm, "limit": 10, "window_ms": tt.window}
+			for i, remaining := range []int64{9, 8} {
+				var res RateLimitResponse
+				code := serve(t, s, newRequest(t, http.MethodPost, "/ratelimit", req), &res)
+				if code != tt.code {
+					t.Fatalf("got status %d, want %d", code, tt.code)
+				}
+				if code != http.StatusOK {
+					return
+				}
+
+				// The limiter is kept between takes, it doesn't expire right away.
+				if !res.Allowed || res.Remaining != remaining {
+					t.Fatalf("got %+v from take %d, want %d remaining", res, i, remaining)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"github.com/mojixcoder/caster/internal/rpc"
+	"github.com/mojixcoder/caster/internal/writebehind"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// rpcHandler handles the RPC calls of other nodes on the local cache.
+type rpcHandler struct {
+	s Server
+}
+
+// getLocal gets a key from the local cache, it's loaded from its origin on a miss if it has a loader.
+func (s Server) getLocal(ctx context.Context, key string) (GetResponse, error) {
+	span := tracesdk.SpanFromContext(ctx)
+
+	item, err := s.cache.GetItem(key)
+	if err == cache.ErrNotFound && s.loader.Has(key) {
+		span.SetAttributes(attribute.Bool("read_through", true))
+		var val any
+		val, err = s.loader.Load(ctx, key)
+		item.Value = cache.NewScalar(val)
+	}
+	if err != nil {
+		return GetResponse{}, err
+	}
+	span.SetAttributes(attribute.Bool("key_found", true))
+
+	scalar, ok := item.Value.(cache.Scalar)
+	if !ok {
+		return GetResponse{}, cache.ErrWrongType
+	}
+
+	stale := item.IsStale(time.Now())
+	span.SetAttributes(attribute.Bool("stale", stale))
+	if stale && s.loader.Has(key) {
+		s.loader.Refresh(ctx, key)
+	}
+
+	return GetResponse{Value: scalar.Get(), Stale: stale, Version: item.Version, Timestamp: item.Timestamp}, nil
+}
+
+// stamp returns the timestamp of a write received by the node.
+// Writes of clients are timestamped by the node's clock, writes coordinated by other nodes
+// keep their timestamps, which are merged into the clock so the node's later writes are ordered after them.
+// It returns hlc.ErrTooFarAhead if the timestamp of another node is too far ahead of the node's clock.
+func (s Server) stamp(ts hlc.Timestamp) (hlc.Timestamp, error) {
+	if ts == 0 {
+		return s.clock.Now(), nil
+	}
+
+	if _, err := s.clock.Update(ts); err != nil {
+		return 0, err
+	}
+	return ts, nil
+}
+
+// setLocal sets a key to the local cache, val is the JSON encoded value, TTLs are in milliseconds and ts is the timestamp of the write.
+// It returns cache.ErrOutdated if the key has been set by a newer write,
+// and writebehind.ErrQueueFull if the key should be written behind but the queue is full.
+// A write with the timestamp of the key's last write is the same write retried, so it succeeds without changing the key.
+func (s Server) setLocal(ctx context.Context, key string, val json.RawMessage, softTTL, hardTTL int64, ts hlc.Timestamp) error {
+	var value any
+	if len(val) > 0 {
+		if err := json.Unmarshal(val, &value); err != nil {
+			return err
+		}
+	}
+
+	item := cache.NewItem(
+		cache.NewScalar(value),
+		time.Duration(softTTL)*time.Millisecond,
+		time.Duration(hardTTL)*time.Millisecond,
+	)
+	item.Timestamp = ts
+
+	return s.cache.Atomic(func(tx cache.Tx) error {
+		if old, ok := tx.GetItem(key); ok {
+			if old.Timestamp > ts {
+				return cache.ErrOutdated
+			}
+			if old.Timestamp == ts {
+				return nil
+			}
+		}
+
+		if s.writeBehind.Enabled(key) {
+			tracesdk.SpanFromContext(ctx).SetAttributes(attribute.Bool("write_behind", true))
+			if err := s.writeBehind.Mark(key); err != nil {
+				return err
+			}
+		}
+
+		return tx.SetItem(key, item)
+	})
+}
+
+// rpcError writes the response of a failed RPC call to another node.
+func (s Server) rpcError(c *kid.Context, span tracesdk.Span, node cluster.Node, path string, err error) {
+	var rpcErr *rpc.Error
+
+	switch {
+	case err == writebehind.ErrQueueFull:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error in queueing key for write-behind")
+		c.JSON(http.StatusServiceUnavailable, ErrWriteBehindFull)
+	case err == cache.ErrNotFound, err == cache.ErrWrongType, err == cache.ErrTooLarge, err == cache.ErrOutdated:
+		cacheError(c, span, err, "error in calling a cluster member")
+	case errors.As(err, &rpcErr) && rpcErr.Status == rpc.StatusBadRequest:
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": rpcErr.Message})
+	default:
+		peerError(c, span, node, path, err)
+	}
+}
+
+// Get implements rpc.Handler.
+func (h rpcHandler) Get(ctx context.Context, key string) (rpc.GetResult, error) {
+	if key == "" {
+		return rpc.GetResult{}, &rpc.Error{Status: rpc.StatusBadRequest, Message: ErrNoKey.Error()}
+	}
+
+	res, err := h.s.getLocal(ctx, key)
+	if err != nil {
+		return rpc.GetResult{}, err
+	}
+
+	val, err := json.Marshal(res.Value)
+	if err != nil {
+		return rpc.GetResult{}, err
+	}
+
+	return rpc.GetResult{Value: val, Stale: res.Stale, Version: res.Version, Timestamp: res.Timestamp}, nil
+}
+
+// Set implements rpc.Handler.
+func (h rpcHandler) Set(ctx context.Context, key string, val []byte, softTTL, hardTTL int64, ts hlc.Timestamp) error {
+	if key == "" {
+		return &rpc.Error{Status: rpc.StatusBadRequest, Message: ErrNoKey.Error()}
+	}
+
+	if softTTL < 0 || hardTTL < 0 || (hardTTL > 0 && softTTL > hardTTL) {
+		return &rpc.Error{Status: rpc.StatusBadRequest, Message: ErrTTL.Error()}
+	}
+
+	ts, err := h.s.stamp(ts)
+	if err != nil {
+		return &rpc.Error{Status: rpc.StatusBadRequest, Message: err.Error()}
+	}
+
+	err = h.s.setLocal(ctx, key, val, softTTL, hardTTL, ts)
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &rpc.Error{Status: rpc.StatusBadRequest, Message: err.Error()}
+	}
+
+	return err
+}
+
+// Delete implements rpc.Handler.
+func (h rpcHandler) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return &rpc.Error{Status: rpc.StatusBadRequest, Message: ErrNoKey.Error()}
+	}
+
+	return h.s.cache.Delete(key)
+}
+
+// Flush implements rpc.Handler.
+func (h rpcHandler) Flush(ctx context.Context) error {
+	return h.s.cache.Flush()
+}
+
+// runRPCServer runs the RPC server which other nodes call, if an RPC port is configured.
+func (s *Server) runRPCServer() error {
+	port := app.App.Config.Caster.RPCPort
+	if port <= 0 {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+
+	app.App.Logger.Info("running rpc server", zap.String("address", l.Addr().String()))
+
+	go func() {
+		if err := rpc.NewServer(rpcHandler{s: *s}).Serve(l); err != nil {
+			app.App.Logger.Error("rpc server stopped", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
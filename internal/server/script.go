@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/script"
+	"github.com/mojixcoder/caster/internal/writebehind"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+)
+
+type (
+	LoadScriptRequest struct {
+		Source string `json:"source"`
+	}
+
+	LoadScriptResponse struct {
+		ID string `json:"id"`
+	}
+
+	RunScriptRequest struct {
+		ID   string   `json:"id"`
+		Keys []string `json:"keys"`
+		Args []any    `json:"args"`
+	}
+
+	RunScriptResponse struct {
+		Result any `json:"result"`
+
+		// Changed are the keys which the run set or deleted.
+		Changed []string `json:"changed,omitempty"`
+	}
+)
+
+var (
+	ErrSourceRequired = kid.Map{"message": "source is required."}
+
+	ErrScriptIDRequired = kid.Map{"message": "id is required."}
+
+	ErrScriptNotFound = kid.Map{"message": "script not found, it must be loaded first."}
+
+	ErrNoSource = errors.New("source is required")
+
+	ErrNoScriptID = errors.New("id is required")
+)
+
+// LoadScript compiles a script and returns its ID.
+// The script is loaded on every node unless local is true, so it can run on the owner of any key.
+func (s Server) LoadScript(c *kid.Context) {
+	ctx, span := getSpan(c, "load_script")
+	defer span.End()
+
+	var req LoadScriptRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Source == "" {
+		span.RecordError(ErrNoSource)
+		c.JSON(http.StatusBadRequest, ErrSourceRequired)
+		return
+	}
+
+	local, _ := strconv.ParseBool(c.QueryParam("local"))
+
+	id, err := s.scripts.Load(req.Source)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	span.SetAttributes(attribute.String("script", id), attribute.Bool("local", local))
+
+	if !local {
+		app.App.Logger.Debug("loading script on other nodes", zap.String("script", id))
+
+		if err := s.broadcast(ctx, "/scripts/load?local=true", body); err != nil {
+			app.App.Logger.Error(
+				"loading the script on some nodes failed",
+				zap.Error(err),
+				zap.String("path", "/scripts/load"),
+			)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "loading the script on some nodes failed")
+			c.JSON(http.StatusInternalServerError, ErrInternal)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, &LoadScriptResponse{ID: id})
+}
+
+// RunScript runs a loaded script on the owner of its keys.
+// The run is atomic, no other operation sees the keys until the script's changes are applied.
+// The changes are discarded if the script fails.
+func (s Server) RunScript(c *kid.Context) {
+	ctx, span := getSpan(c, "run_script")
+	defer span.End()
+
+	var req RunScriptRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.ID == "" {
+		span.RecordError(ErrNoScriptID)
+		c.JSON(http.StatusBadRequest, ErrScriptIDRequired)
+		return
+	}
+
+	span.SetAttributes(attribute.String("script", req.ID), attribute.StringSlice("keys", req.Keys))
+
+	written := func(body []byte) []string {
+		var res RunScriptResponse
+		if err := json.Unmarshal(body, &res); err != nil {
+			return nil
+		}
+		return res.Changed
+	}
+
+	// A script without keys can run on any node.
+	if len(req.Keys) > 0 && !s.routeWrite(ctx, c, span, req.Keys, body, written) {
+		return
+	}
+
+	// Every change of the run has the same timestamp.
+	ts := s.clock.Now()
+
+	var res RunScriptResponse
+	err = s.cache.Atomic(func(tx cache.Tx) error {
+		var changes *script.Changes
+		var err error
+		res.Result, changes, err = s.scripts.Run(ctx, req.ID, tx, req.Keys, req.Args)
+		if err != nil {
+			return err
+		}
+		res.Changed = changes.Keys()
+
+		for _, key := range changes.Sets() {
+			if s.writeBehind.Enabled(key) {
+				if err := s.writeBehind.Mark(key); err != nil {
+					return err
+				}
+			}
+		}
+
+		return changes.Apply(tx, ts)
+	})
+
+	var scriptErr *script.Error
+	switch {
+	case errors.As(err, &scriptErr):
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	case err == script.ErrNotFound:
+		span.RecordError(err)
+		c.JSON(http.StatusNotFound, ErrScriptNotFound)
+		return
+	case err == writebehind.ErrQueueFull:
+		app.App.Logger.Warn("error in queueing keys for write-behind", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusServiceUnavailable, ErrWriteBehindFull)
+		return
+	case err != nil:
+		cacheError(c, span, err, "error in running script")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
@@ -1,17 +1,38 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/broker"
 	"github.com/mojixcoder/caster/internal/cache"
 	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/handoff"
+	"github.com/mojixcoder/caster/internal/hlc"
+	"github.com/mojixcoder/caster/internal/loader"
+	"github.com/mojixcoder/caster/internal/peer"
+	"github.com/mojixcoder/caster/internal/rpc"
+	"github.com/mojixcoder/caster/internal/script"
+	"github.com/mojixcoder/caster/internal/writebehind"
 	"github.com/mojixcoder/kid"
 	"github.com/mojixcoder/kid/middlewares"
 	"go.uber.org/zap"
 )
 
+const (
+	// maxClockDrift is how far ahead of the node's clock timestamps of writes coordinated by other nodes may be.
+	maxClockDrift = time.Minute
+
+	// shutdownTimeout is how long running requests are waited for when the server shuts down.
+	shutdownTimeout = 10 * time.Second
+)
+
 // Server manages server-related stuff.
 type Server struct {
 	// cluster is the cluster manager.
@@ -20,6 +41,33 @@ type Server struct {
 	// cache is the cache storage.
 	cache cache.Cache
 
+	// loader loads missing keys from their origins.
+	loader *loader.Loader
+
+	// writeBehind flushes writes of some keys to an external sink.
+	writeBehind *writebehind.Queue
+
+	// peers is the client which calls other nodes.
+	peers *peer.Client
+
+	// rpc is the client which calls the RPC servers of other nodes.
+	rpc *rpc.Client
+
+	// hints keeps writes to unreachable nodes until they're delivered.
+	hints *handoff.Store
+
+	// clock timestamps the writes which the node coordinates.
+	clock *hlc.Clock
+
+	// scripts holds the loaded server-side scripts.
+	scripts *script.Scripts
+
+	// events broadcasts keyspace events of the local cache.
+	events *broker.Broker[cache.Event]
+
+	// channels broadcasts messages published to pub/sub channels.
+	channels *broker.Broker[Message]
+
 	kid *kid.Kid
 }
 
@@ -36,13 +84,86 @@ func (s *Server) RunServer() error {
 
 	s.initHandlers()
 
+	s.cluster.StartProbes()
+	s.hints.Start(s.deliverHint)
+
+	if err := s.runRPCServer(); err != nil {
+		return err
+	}
+
+	grpcServer, err := s.runGRPCServer()
+	if err != nil {
+		return err
+	}
+
 	port := fmt.Sprintf(":%d", app.App.Config.Caster.Port)
 	app.App.Logger.Info("running server", zap.String("address", "0.0.0.0"+port))
 
-	return s.kid.Run(port)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: port, Handler: s.kid}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	app.App.Logger.Info("shutting down server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if grpcServer != nil {
+		stopGRPCServer(ctx, grpcServer)
+	}
+
+	// Streams like subscriptions don't end on their own, so they're cut once the timeout passes.
+	if err := srv.Shutdown(ctx); err != nil {
+		app.App.Logger.Warn("closing connections which didn't finish in time", zap.Error(err))
+		srv.Close()
+	}
+
+	return http.ErrServerClosed
+}
+
+// newEventBroker returns a broker which receives keyspace events of the cache.
+func newEventBroker(c cache.Cache) *broker.Broker[cache.Event] {
+	events := broker.New[cache.Event]()
+	c.SetListener(events.Publish)
+	return events
 }
 
 // NewServer returns a new server.
-func NewServer(cache cache.Cache, cluster cluster.Cluster) *Server {
-	return &Server{cache: cache, cluster: cluster, kid: kid.New()}
+func NewServer(
+	cache cache.Cache,
+	cluster cluster.Cluster,
+	loader *loader.Loader,
+	writeBehind *writebehind.Queue,
+) *Server {
+	peers := peer.NewClient()
+
+	// Writes which aren't timestamped by their handlers, like updates of typed values, are timestamped by the cache.
+	clock := hlc.NewClock(maxClockDrift)
+	cache.SetClock(clock.Now)
+
+	return &Server{
+		cache:       cache,
+		cluster:     cluster,
+		loader:      loader,
+		writeBehind: writeBehind,
+		peers:       peers,
+		rpc:         rpc.NewClient(peers),
+		hints:       handoff.NewStore(),
+		clock:       clock,
+		scripts:     script.New(),
+		events:      newEventBroker(cache),
+		channels:    broker.New[Message](),
+		kid:         kid.New(),
+	}
 }
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/caster/internal/config"
+	"github.com/mojixcoder/caster/internal/loader"
+	"github.com/mojixcoder/caster/internal/writebehind"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	app.App = &app.AppRepo{Logger: zap.NewNop(), Config: &config.AppConfig{Caster: &config.CasterConfig{}}}
+	os.Exit(m.Run())
+}
+
+// newTestServer returns a server whose handlers are registered.
+// The cluster has the given nodes, or only the local node if none is given.
+func newTestServer(t *testing.T, nodes ...config.NodeConfig) *Server {
+	t.Helper()
+
+	old := app.App.Config.Nodes
+	app.App.Config.Nodes = nodes
+	t.Cleanup(func() { app.App.Config.Nodes = old })
+
+	c := cache.NewLRUCache()
+	cl, err := cluster.NewCluster()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(c, cl, loader.NewLoader(c), writebehind.NewQueue(c))
+	s.initHandlers()
+
+	return s
+}
+
+// newRequest returns a request whose body is in encoded as JSON, in can be nil.
+func newRequest(t *testing.T, method, target string, in any) *http.Request {
+	t.Helper()
+
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// serve sends the request to the server and decodes the body of a successful response into out, which can be nil.
+// The status code of the response is returned.
+func serve(t *testing.T, s *Server, req *http.Request, out any) int {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.kid.ServeHTTP(rec, req)
+
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("decoding %s: %v", rec.Body.String(), err)
+		}
+	}
+
+	return rec.Code
+}
+
+// ownedKey returns a key whose node is the node with the index.
+func ownedKey(t *testing.T, s *Server, prefix string, index int) string {
+	t.Helper()
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("%s%d", prefix, i)
+		if s.cluster.GetNodeFromKey(key).Index() == index {
+			return key
+		}
+	}
+
+	t.Fatalf("no key of node %d", index)
+	return ""
+}
@@ -0,0 +1,334 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type (
+	SetMembersRequest struct {
+		Key     string   `json:"key"`
+		Members []string `json:"members"`
+	}
+
+	SAddResponse struct {
+		Added int `json:"added"`
+	}
+
+	SRemResponse struct {
+		Removed int `json:"removed"`
+	}
+
+	SIsMemberResponse struct {
+		IsMember bool `json:"is_member"`
+	}
+
+	SMembersResponse struct {
+		Members []string `json:"members"`
+	}
+
+	SCardResponse struct {
+		Cardinality int `json:"cardinality"`
+	}
+)
+
+var ErrKeysRequired = kid.Map{"message": "at least a key is required."}
+
+// asSet returns the value as a set. A nil value is returned as a new set if create is true.
+func asSet(val cache.Value, create bool) (*cache.Set, error) {
+	if val == nil {
+		if create {
+			return cache.NewSet(), nil
+		}
+		return nil, cache.ErrNotFound
+	}
+
+	set, ok := val.(*cache.Set)
+	if !ok {
+		return nil, cache.ErrWrongType
+	}
+
+	return set, nil
+}
+
+// SAdd adds members to a set.
+func (s Server) SAdd(c *kid.Context) {
+	ctx, span := getSpan(c, "sadd")
+	defer span.End()
+
+	var req SetMembersRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if len(req.Members) == 0 {
+		span.RecordError(ErrNoMember)
+		c.JSON(http.StatusBadRequest, ErrMembersRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res SAddResponse
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		set, err := asSet(val, true)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Added = set.Add(req.Members...)
+
+		return set, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in adding set members")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// SRem removes members from a set. The set is deleted when it has no members left.
+func (s Server) SRem(c *kid.Context) {
+	ctx, span := getSpan(c, "srem")
+	defer span.End()
+
+	var req SetMembersRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if len(req.Members) == 0 {
+		span.RecordError(ErrNoMember)
+		c.JSON(http.StatusBadRequest, ErrMembersRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res SRemResponse
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		if val == nil {
+			return nil, nil
+		}
+
+		set, err := asSet(val, false)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Removed = set.Remove(req.Members...)
+		if set.Len() == 0 {
+			return nil, nil
+		}
+
+		return set, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in removing set members")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// SIsMember determines if a member is in a set.
+func (s Server) SIsMember(c *kid.Context) {
+	ctx, span := getSpan(c, "sismember")
+	defer span.End()
+
+	key, member := c.QueryParam("key"), c.QueryParam("member")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if member == "" {
+		span.RecordError(ErrNoMember)
+		c.JSON(http.StatusBadRequest, ErrMemberRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	var res SIsMemberResponse
+	err := s.cache.View(key, func(val cache.Value) error {
+		set, err := asSet(val, false)
+		if err != nil {
+			return err
+		}
+
+		res.IsMember = set.Has(member)
+
+		return nil
+	})
+	if err != nil && err != cache.ErrNotFound {
+		cacheError(c, span, err, "error in checking set membership")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// SMembers gets the members of a set. A missing set has no members.
+func (s Server) SMembers(c *kid.Context) {
+	ctx, span := getSpan(c, "smembers")
+	defer span.End()
+
+	key := c.QueryParam("key")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	res := SMembersResponse{Members: []string{}}
+	err := s.cache.View(key, func(val cache.Value) error {
+		set, err := asSet(val, false)
+		if err != nil {
+			return err
+		}
+
+		res.Members = set.Members()
+
+		return nil
+	})
+	if err != nil && err != cache.ErrNotFound {
+		cacheError(c, span, err, "error in getting set members")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// SCard gets the number of members of a set.
+func (s Server) SCard(c *kid.Context) {
+	ctx, span := getSpan(c, "scard")
+	defer span.End()
+
+	key := c.QueryParam("key")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	var res SCardResponse
+	err := s.cache.View(key, func(val cache.Value) error {
+		set, err := asSet(val, false)
+		if err != nil {
+			return err
+		}
+
+		res.Cardinality = set.Len()
+
+		return nil
+	})
+	if err != nil && err != cache.ErrNotFound {
+		cacheError(c, span, err, "error in getting set cardinality")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// SInter gets the members which are in every given set.
+func (s Server) SInter(c *kid.Context) {
+	s.algebra(c, "sinter", cache.Intersect)
+}
+
+// SUnion gets the members which are in at least one of the given sets.
+func (s Server) SUnion(c *kid.Context) {
+	s.algebra(c, "sunion", cache.Union)
+}
+
+// SDiff gets the members of the first given set which aren't in the other ones.
+func (s Server) SDiff(c *kid.Context) {
+	s.algebra(c, "sdiff", cache.Diff)
+}
+
+// algebra performs a set operation on sets which live on the same node.
+func (s Server) algebra(c *kid.Context, name string, op func(sets ...*cache.Set) []string) {
+	ctx, span := getSpan(c, name)
+	defer span.End()
+
+	keys := c.QueryParamMultiple("key")
+	if len(keys) == 0 {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeysRequired)
+		return
+	}
+
+	span.SetAttributes(attribute.StringSlice("keys", keys))
+
+	if !s.routeKeys(ctx, c, span, keys, nil) {
+		return
+	}
+
+	var res SMembersResponse
+	err := s.cache.Atomic(func(tx cache.Tx) error {
+		sets := make([]*cache.Set, len(keys))
+		for i, key := range keys {
+			val := tx.Get(key)
+			if val == nil {
+				continue
+			}
+
+			set, err := asSet(val, false)
+			if err != nil {
+				return err
+			}
+			sets[i] = set
+		}
+
+		res.Members = op(sets...)
+
+		return nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in performing set operation")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/cluster"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// streamBufferSize is the number of messages buffered for each stream.
+	// Messages are dropped for streams which can't keep up.
+	streamBufferSize = 256
+
+	// heartbeatInterval is the interval of heartbeats sent to idle streams.
+	heartbeatInterval = 15 * time.Second
+
+	// relayRetryInterval is the time waited before reconnecting to a node's stream.
+	relayRetryInterval = time.Second
+)
+
+// dataPrefix is the prefix of data lines in server-sent events.
+var dataPrefix = []byte("data: ")
+
+// hasPrefix determines if the key has one of the prefixes. Empty prefixes match every key.
+func hasPrefix(key string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stream writes the messages as server-sent events until the client goes away.
+func stream(c *kid.Context, messages <-chan []byte) {
+	c.SetResponseHeader("Content-Type", "text/event-stream")
+	c.SetResponseHeader("Cache-Control", "no-cache")
+	c.SetResponseHeader("Connection", "keep-alive")
+
+	res := c.Response()
+	res.WriteHeader(http.StatusOK)
+	res.WriteHeaderNow()
+	res.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	done := c.Request().Context().Done()
+	for {
+		select {
+		case <-done:
+			return
+		case msg := <-messages:
+			if _, err := fmt.Fprintf(res, "data: %s\n\n", msg); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := res.Write([]byte(": heartbeat\n\n")); err != nil {
+				return
+			}
+		}
+		res.Flush()
+	}
+}
+
+// relay relays the server-sent events of the given path on another node to out until ctx is done.
+// It reconnects if the node's stream breaks.
+func (s Server) relay(ctx, traceCtx context.Context, node cluster.Node, path string, query url.Values, out chan<- []byte) {
+	query.Set("local", "true")
+	address := s.mergeAddressAndPath(node.Address(), path) + "?" + query.Encode()
+
+	for {
+		if err := s.relayOnce(ctx, traceCtx, address, out); err != nil && ctx.Err() == nil {
+			app.App.Logger.Error(
+				"error in relaying stream of a cluster member",
+				zap.String("node", node.Address()),
+				zap.String("path", path),
+				zap.Error(err),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(relayRetryInterval):
+		}
+	}
+}
+
+// relayOnce relays a single connection to another node's stream.
+func (s Server) relayOnce(ctx, traceCtx context.Context, address string, out chan<- []byte) error {
+	req, _ := http.NewRequest(http.MethodGet, address, nil)
+	req = injectReq(traceCtx, req).WithContext(ctx)
+
+	res, err := s.peers.Stream(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("cluster member responded with status %d", res.StatusCode)
+	}
+
+	reader := bufio.NewReader(res.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+
+		if !bytes.HasPrefix(line, dataPrefix) {
+			continue
+		}
+
+		select {
+		case out <- bytes.TrimSpace(line[len(dataPrefix):]):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Subscribe streams keyspace events of keys with the given prefixes as server-sent events.
+// Events of every node are streamed unless local is true.
+// Expired events are sent when an expired key is accessed or swept, so they may be up to the expiry interval late.
+func (s Server) Subscribe(c *kid.Context) {
+	ctx, span := getSpan(c, "subscribe")
+	defer span.End()
+
+	prefixes := c.QueryParamMultiple("prefix")
+	local, _ := strconv.ParseBool(c.QueryParam("local"))
+
+	span.SetAttributes(attribute.StringSlice("prefixes", prefixes), attribute.Bool("local", local))
+
+	sub := s.events.Subscribe(streamBufferSize, func(e cache.Event) bool {
+		return e.Op == cache.OpFlush || hasPrefix(e.Key, prefixes)
+	})
+	defer s.events.Unsubscribe(sub)
+
+	reqCtx := c.Request().Context()
+	out := make(chan []byte, streamBufferSize)
+
+	go func() {
+		for e := range sub.C {
+			msg, _ := json.Marshal(e)
+			select {
+			case out <- msg:
+			case <-reqCtx.Done():
+				return
+			}
+		}
+	}()
+
+	if !local {
+		for _, node := range s.cluster.NonLocalNodes() {
+			go s.relay(reqCtx, ctx, node, "/subscribe", c.QueryParams(), out)
+		}
+	}
+
+	stream(c, out)
+}
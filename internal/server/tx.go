@@ -0,0 +1,254 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/writebehind"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// TxOpSet sets a key.
+	TxOpSet = "set"
+
+	// TxOpDelete deletes a key.
+	TxOpDelete = "delete"
+
+	// TxOpCheck only checks the conditions of a key.
+	TxOpCheck = "check"
+)
+
+type (
+	TxRequest struct {
+		Ops []TxOp `json:"ops"`
+	}
+
+	// TxOp is an operation of a transaction, it's applied only if all of its conditions hold.
+	TxOp struct {
+		Op      string `json:"op"`
+		Key     string `json:"key"`
+		Value   any    `json:"value"`
+		SoftTTL int64  `json:"soft_ttl_ms"`
+		HardTTL int64  `json:"hard_ttl_ms"`
+
+		// IfExists requires the key to exist or not.
+		IfExists *bool `json:"if_exists"`
+		// IfVersion requires the key to have the version, zero requires the key not to exist.
+		IfVersion *uint64 `json:"if_version"`
+		// IfValue requires the key to hold the value.
+		IfValue json.RawMessage `json:"if_value"`
+	}
+
+	TxResponse struct {
+		// Versions are the versions of the keys after every operation, zero if the key doesn't exist.
+		Versions []uint64 `json:"versions"`
+	}
+
+	// conditionError is raised when a condition of an operation doesn't hold.
+	conditionError struct {
+		op  int
+		key string
+	}
+)
+
+var (
+	ErrOpsRequired = kid.Map{"message": "at least an op is required."}
+
+	ErrInvalidOp = kid.Map{"message": "op must be one of set, delete or check, and its key is required."}
+
+	ErrNoOp = errors.New("op is required")
+)
+
+// Error implements the error interface.
+func (e conditionError) Error() string {
+	return fmt.Sprintf("condition of op %d on key %q failed", e.op, e.key)
+}
+
+// holds determines if the conditions of the operation hold for the item.
+func (op TxOp) holds(item cache.Item, exists bool) bool {
+	if op.IfExists != nil && *op.IfExists != exists {
+		return false
+	}
+
+	if op.IfVersion != nil && *op.IfVersion != item.Version {
+		return false
+	}
+
+	if op.IfValue != nil {
+		if !exists {
+			return false
+		}
+
+		scalar, ok := item.Value.(cache.Scalar)
+		if !ok {
+			return false
+		}
+
+		// It's valid JSON, it's already been decoded while reading the request.
+		var val any
+		_ = json.Unmarshal(op.IfValue, &val)
+
+		if !reflect.DeepEqual(scalar.Get(), val) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Tx applies a batch of conditional operations atomically.
+// Either all operations are applied or none of them, the conditions are checked against the keys before the batch.
+// The keys must live on the same node, hash tags can be used to make sure they do.
+func (s Server) Tx(c *kid.Context) {
+	ctx, span := getSpan(c, "tx")
+	defer span.End()
+
+	var req TxRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if len(req.Ops) == 0 {
+		span.RecordError(ErrNoOp)
+		c.JSON(http.StatusBadRequest, ErrOpsRequired)
+		return
+	}
+
+	keys := make([]string, len(req.Ops))
+	for i, op := range req.Ops {
+		if op.Key == "" || (op.Op != TxOpSet && op.Op != TxOpDelete && op.Op != TxOpCheck) {
+			c.JSON(http.StatusBadRequest, ErrInvalidOp)
+			return
+		}
+
+		if op.SoftTTL < 0 || op.HardTTL < 0 || (op.HardTTL > 0 && op.SoftTTL > op.HardTTL) {
+			span.RecordError(ErrTTL)
+			c.JSON(http.StatusBadRequest, ErrInvalidTTL)
+			return
+		}
+
+		keys[i] = op.Key
+	}
+
+	span.SetAttributes(attribute.StringSlice("keys", keys))
+
+	written := func([]byte) []string {
+		var keys []string
+		for _, op := range req.Ops {
+			if op.Op != TxOpCheck {
+				keys = append(keys, op.Key)
+			}
+		}
+		return keys
+	}
+	if !s.routeWrite(ctx, c, span, keys, body, written) {
+		return
+	}
+
+	// Every write of the transaction has the same timestamp.
+	ts := s.clock.Now()
+	items := make([]cache.Item, len(req.Ops))
+	for i, op := range req.Ops {
+		if op.Op == TxOpSet {
+			items[i] = cache.NewItem(
+				cache.NewScalar(op.Value),
+				time.Duration(op.SoftTTL)*time.Millisecond,
+				time.Duration(op.HardTTL)*time.Millisecond,
+			)
+			items[i].Timestamp = ts
+		}
+	}
+
+	res := TxResponse{Versions: make([]uint64, len(req.Ops))}
+	err = s.cache.Atomic(func(tx cache.Tx) error {
+		// Nothing is changed until every condition is checked.
+		for i, op := range req.Ops {
+			item, exists := tx.GetItem(op.Key)
+			if !op.holds(item, exists) {
+				return conditionError{op: i, key: op.Key}
+			}
+		}
+
+		// Only the last write of every key is applied, the earlier ones would be overwritten anyway.
+		last := make(map[string]int, len(req.Ops))
+		for i, op := range req.Ops {
+			if op.Op != TxOpCheck {
+				last[op.Key] = i
+			}
+		}
+
+		// The values must fit together, otherwise setting the later ones could evict the earlier ones.
+		vals := make([]cache.Value, 0, len(last))
+		for _, i := range last {
+			if req.Ops[i].Op == TxOpSet {
+				vals = append(vals, items[i].Value)
+			}
+		}
+
+		if !tx.Fits(vals...) {
+			return cache.ErrTooLarge
+		}
+
+		for _, op := range req.Ops {
+			if op.Op == TxOpSet && s.writeBehind.Enabled(op.Key) {
+				if err := s.writeBehind.Mark(op.Key); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i, op := range req.Ops {
+			if last[op.Key] != i {
+				continue
+			}
+
+			switch op.Op {
+			case TxOpSet:
+				if err := tx.SetItem(op.Key, items[i]); err != nil {
+					return err
+				}
+			case TxOpDelete:
+				tx.Delete(op.Key)
+			}
+		}
+
+		// Later operations may change keys of earlier ones, so versions are read at the end.
+		for i, op := range req.Ops {
+			item, _ := tx.GetItem(op.Key)
+			res.Versions[i] = item.Version
+		}
+
+		return nil
+	})
+
+	var condErr conditionError
+	switch {
+	case errors.As(err, &condErr):
+		span.SetAttributes(attribute.Int("failed_op", condErr.op))
+		c.JSON(http.StatusConflict, kid.Map{"message": condErr.Error(), "op": condErr.op})
+		return
+	case err == writebehind.ErrQueueFull:
+		app.App.Logger.Warn("error in queueing keys for write-behind", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusServiceUnavailable, ErrWriteBehindFull)
+		return
+	case err != nil:
+		cacheError(c, span, err, "error in applying transaction")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
@@ -0,0 +1,420 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/kid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type (
+	ZAddRequest struct {
+		Key     string             `json:"key"`
+		Members map[string]float64 `json:"members"`
+	}
+
+	ZAddResponse struct {
+		Added int `json:"added"`
+	}
+
+	ZIncrByRequest struct {
+		Key       string  `json:"key"`
+		Member    string  `json:"member"`
+		Increment float64 `json:"increment"`
+	}
+
+	ZIncrByResponse struct {
+		Score float64 `json:"score"`
+	}
+
+	ZRemRequest struct {
+		Key     string   `json:"key"`
+		Members []string `json:"members"`
+	}
+
+	ZRemResponse struct {
+		Removed int `json:"removed"`
+	}
+
+	ZRangeResponse struct {
+		Members []cache.ScoredMember `json:"members"`
+	}
+
+	ZRankResponse struct {
+		Rank  int     `json:"rank"`
+		Score float64 `json:"score"`
+	}
+)
+
+var (
+	ErrMemberRequired = kid.Map{"message": "member is required."}
+
+	ErrMembersRequired = kid.Map{"message": "at least a member is required."}
+
+	ErrInvalidScoreRange = kid.Map{"message": "min and max must be numbers, offset and limit must be integers."}
+
+	ErrNoMember = errors.New("member is required")
+)
+
+// asZSet returns the value as a sorted set. A nil value is returned as a new sorted set if create is true.
+func asZSet(val cache.Value, create bool) (*cache.ZSet, error) {
+	if val == nil {
+		if create {
+			return cache.NewZSet(), nil
+		}
+		return nil, cache.ErrNotFound
+	}
+
+	zset, ok := val.(*cache.ZSet)
+	if !ok {
+		return nil, cache.ErrWrongType
+	}
+
+	return zset, nil
+}
+
+// ZAdd adds members to a sorted set or updates their scores.
+func (s Server) ZAdd(c *kid.Context) {
+	ctx, span := getSpan(c, "zadd")
+	defer span.End()
+
+	var req ZAddRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if len(req.Members) == 0 {
+		span.RecordError(ErrNoMember)
+		c.JSON(http.StatusBadRequest, ErrMembersRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res ZAddResponse
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		zset, err := asZSet(val, true)
+		if err != nil {
+			return nil, err
+		}
+
+		for member, score := range req.Members {
+			if zset.Add(member, score) {
+				res.Added++
+			}
+		}
+
+		return zset, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in adding sorted set members")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// ZIncrBy increments the score of a sorted set member.
+func (s Server) ZIncrBy(c *kid.Context) {
+	ctx, span := getSpan(c, "zincrby")
+	defer span.End()
+
+	var req ZIncrByRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if req.Member == "" {
+		span.RecordError(ErrNoMember)
+		c.JSON(http.StatusBadRequest, ErrMemberRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res ZIncrByResponse
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		zset, err := asZSet(val, true)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Score, err = zset.IncrBy(req.Member, req.Increment)
+		if err != nil {
+			return nil, err
+		}
+
+		return zset, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in incrementing sorted set member")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// ZRem removes members from a sorted set. The sorted set is deleted when it has no members left.
+func (s Server) ZRem(c *kid.Context) {
+	ctx, span := getSpan(c, "zrem")
+	defer span.End()
+
+	var req ZRemRequest
+	body, err := readJSON(c, &req)
+	if err != nil {
+		app.App.Logger.Error("error in reading request body", zap.Error(err))
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, kid.Map{"message": err.Error()})
+		return
+	}
+
+	if req.Key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if len(req.Members) == 0 {
+		span.RecordError(ErrNoMember)
+		c.JSON(http.StatusBadRequest, ErrMembersRequired)
+		return
+	}
+
+	if !s.route(ctx, c, span, req.Key, body) {
+		return
+	}
+
+	var res ZRemResponse
+	err = s.cache.Update(req.Key, func(val cache.Value) (cache.Value, error) {
+		if val == nil {
+			return nil, nil
+		}
+
+		zset, err := asZSet(val, false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, member := range req.Members {
+			if zset.Remove(member) {
+				res.Removed++
+			}
+		}
+
+		if zset.Len() == 0 {
+			return nil, nil
+		}
+
+		return zset, nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in removing sorted set members")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// ZRange gets the members of a sorted set with ranks between start and stop, both inclusive,
+// from the lowest score to the highest.
+func (s Server) ZRange(c *kid.Context) {
+	s.zrange(c, "zrange", false)
+}
+
+// ZRevRange gets the members of a sorted set with ranks between start and stop, both inclusive,
+// from the highest score to the lowest.
+func (s Server) ZRevRange(c *kid.Context) {
+	s.zrange(c, "zrevrange", true)
+}
+
+// zrange gets a range of a sorted set by rank.
+func (s Server) zrange(c *kid.Context, name string, reverse bool) {
+	ctx, span := getSpan(c, name)
+	defer span.End()
+
+	key := c.QueryParam("key")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	start, err := queryInt(c, "start", 0)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, ErrInvalidRange)
+		return
+	}
+
+	stop, err := queryInt(c, "stop", -1)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, ErrInvalidRange)
+		return
+	}
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	res := ZRangeResponse{Members: []cache.ScoredMember{}}
+	err = s.cache.View(key, func(val cache.Value) error {
+		zset, err := asZSet(val, false)
+		if err != nil {
+			return err
+		}
+
+		res.Members = zset.Range(start, stop, reverse)
+
+		return nil
+	})
+	if err != nil && err != cache.ErrNotFound {
+		cacheError(c, span, err, "error in getting sorted set range")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// ZRangeByScore gets the members of a sorted set with scores between min and max, both inclusive.
+// Infinite bounds are given as -inf and +inf.
+func (s Server) ZRangeByScore(c *kid.Context) {
+	ctx, span := getSpan(c, "zrangebyscore")
+	defer span.End()
+
+	key := c.QueryParam("key")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	min, err := queryFloat(c, "min", math.Inf(-1))
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, ErrInvalidScoreRange)
+		return
+	}
+
+	max, err := queryFloat(c, "max", math.Inf(1))
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, ErrInvalidScoreRange)
+		return
+	}
+
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, ErrInvalidScoreRange)
+		return
+	}
+
+	limit, err := queryInt(c, "limit", -1)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, ErrInvalidScoreRange)
+		return
+	}
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	res := ZRangeResponse{Members: []cache.ScoredMember{}}
+	err = s.cache.View(key, func(val cache.Value) error {
+		zset, err := asZSet(val, false)
+		if err != nil {
+			return err
+		}
+
+		res.Members = zset.RangeByScore(min, max, offset, limit)
+
+		return nil
+	})
+	if err != nil && err != cache.ErrNotFound {
+		cacheError(c, span, err, "error in getting sorted set range by score")
+		return
+	}
+
+	c.JSON(http.StatusOK, &res)
+}
+
+// ZRank gets the rank of a sorted set member, from the lowest score or from the highest if reverse is true.
+func (s Server) ZRank(c *kid.Context) {
+	ctx, span := getSpan(c, "zrank")
+	defer span.End()
+
+	key, member := c.QueryParam("key"), c.QueryParam("member")
+	if key == "" {
+		span.RecordError(ErrNoKey)
+		c.JSON(http.StatusBadRequest, ErrKeyRequired)
+		return
+	}
+
+	if member == "" {
+		span.RecordError(ErrNoMember)
+		c.JSON(http.StatusBadRequest, ErrMemberRequired)
+		return
+	}
+
+	reverse, _ := strconv.ParseBool(c.QueryParam("reverse"))
+
+	if !s.route(ctx, c, span, key, nil) {
+		return
+	}
+
+	var res ZRankResponse
+	err := s.cache.View(key, func(val cache.Value) error {
+		zset, err := asZSet(val, false)
+		if err != nil {
+			return err
+		}
+
+		rank, ok := zset.Rank(member, reverse)
+		if !ok {
+			return cache.ErrNotFound
+		}
+		res.Rank = rank
+		res.Score, _ = zset.Score(member)
+
+		return nil
+	})
+	if err != nil {
+		cacheError(c, span, err, "error in getting sorted set rank")
+		return
+	}
+	span.SetAttributes(attribute.Bool("key_found", true))
+
+	c.JSON(http.StatusOK, &res)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestZRangeByScoreBounds(t *testing.T) {
+	s := newTestServer(t)
+
+	add := ZAddRequest{Key: "zset", Members: map[string]float64{"a": -1e300, "b": 0, "c": 1e300}}
+	if code := serve(t, s, newRequest(t, http.MethodPost, "/zadd", &add), nil); code != http.StatusOK {
+		t.Fatalf("got status %d from zadd", code)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		code    int
+		members []string
+	}{
+		{name: "no bounds", query: "", code: http.StatusOK, members: []string{"a", "b", "c"}},
+		{name: "infinite bounds", query: "&min=-inf&max=%2Binf", code: http.StatusOK, members: []string{"a", "b", "c"}},
+		{name: "unescaped plus", query: "&min=-inf&max=+inf", code: http.StatusOK, members: []string{"a", "b", "c"}},
+		{name: "finite bounds", query: "&min=0&max=inf", code: http.StatusOK, members: []string{"b", "c"}},
+		{name: "nan", query: "&min=nan", code: http.StatusBadRequest},
+		{name: "not a number", query: "&max=a", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res ZRangeResponse
+			code := serve(t, s, newRequest(t, http.MethodGet, "/zrangebyscore?key=zset"+tt.query, nil), &res)
+			if code != tt.code {
+				t.Fatalf("got status %d, want %d", code, tt.code)
+			}
+
+			if len(res.Members) != len(tt.members) {
+				t.Fatalf("got %v, want %v", res.Members, tt.members)
+			}
+			for i, m := range res.Members {
+				if m.Member != tt.members[i] {
+					t.Errorf("got %v, want %v", res.Members, tt.members)
+				}
+			}
+		})
+	}
+}
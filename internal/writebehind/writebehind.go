@@ -0,0 +1,258 @@
+package writebehind
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/app"
+	"github.com/mojixcoder/caster/internal/cache"
+	"github.com/mojixcoder/caster/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.uber.org/zap"
+)
+
+// ErrQueueFull is returned when there is no room left for another dirty key.
+var ErrQueueFull = errors.New("write-behind queue is full")
+
+// Entry is a key-value pair sent to the sink.
+type Entry struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// Queue collects dirty keys on the owning node and flushes their latest values to a sink in batches.
+type Queue struct {
+	cache  cache.Cache
+	cfg    config.WriteBehindConfig
+	client *http.Client
+
+	mutex *sync.Mutex
+	dirty map[string]struct{}
+
+	// full is signaled when a full batch is waiting, so it doesn't wait for the next tick.
+	full chan struct{}
+}
+
+// NewQueue returns a new write-behind queue.
+func NewQueue(c cache.Cache) *Queue {
+	cfg := app.App.Config.WriteBehind
+
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = 100 * time.Millisecond
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	return &Queue{
+		cache:  c,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		mutex:  new(sync.Mutex),
+		dirty:  make(map[string]struct{}),
+		full:   make(chan struct{}, 1),
+	}
+}
+
+// Enabled determines if writes to the key should be flushed to the sink.
+func (q *Queue) Enabled(key string) bool {
+	if q.cfg.Sink == "" {
+		return false
+	}
+
+	if len(q.cfg.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range q.cfg.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mark marks the key as dirty. Marking an already dirty key is a no-op,
+// so a key is flushed once per batch no matter how many times it was written.
+func (q *Queue) Mark(key string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if _, ok := q.dirty[key]; ok {
+		return nil
+	}
+
+	if len(q.dirty) >= q.cfg.QueueSize {
+		return ErrQueueFull
+	}
+
+	q.dirty[key] = struct{}{}
+
+	if len(q.dirty) >= q.cfg.BatchSize {
+		select {
+		case q.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Start starts flushing dirty keys in the background.
+// It's a no-op if write-behind is disabled.
+func (q *Queue) Start() {
+	if q.cfg.Sink == "" {
+		return
+	}
+
+	app.App.Logger.Info(
+		"starting write-behind queue",
+		zap.String("sink", q.cfg.Sink),
+		zap.Duration("flush_interval", q.cfg.FlushInterval),
+	)
+
+	go func() {
+		ticker := time.NewTicker(q.cfg.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-q.full:
+			}
+			q.Flush()
+		}
+	}()
+}
+
+// Flush sends all dirty keys to the sink.
+func (q *Queue) Flush() {
+	q.mutex.Lock()
+	keys := make([]string, 0, len(q.dirty))
+	for key := range q.dirty {
+		keys = append(keys, key)
+	}
+	q.dirty = make(map[string]struct{})
+	q.mutex.Unlock()
+
+	for i := 0; i < len(keys); i += q.cfg.BatchSize {
+		end := i + q.cfg.BatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		batch := keys[i:end]
+		if err := q.flushBatch(batch); err != nil {
+			app.App.Logger.Error("error in flushing write-behind batch", zap.Int("size", len(batch)), zap.Error(err))
+			q.requeue(batch)
+		}
+	}
+}
+
+// requeue marks the keys as dirty again so they're retried in the next flush.
+func (q *Queue) requeue(keys []string) {
+	var dropped int
+	for _, key := range keys {
+		if err := q.Mark(key); err != nil {
+			dropped++
+		}
+	}
+
+	if dropped > 0 {
+		app.App.Logger.Error("dropped write-behind keys", zap.Int("count", dropped), zap.Error(ErrQueueFull))
+	}
+}
+
+// flushBatch posts the latest values of the keys to the sink, retrying with backoff.
+func (q *Queue) flushBatch(keys []string) error {
+	ctx, span := otel.Tracer(app.App.Config.Tracer.Name).Start(context.Background(), "write_behind_flush")
+	defer span.End()
+
+	entries := make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		val, err := q.cache.Get(key)
+		if err != nil {
+			// The key is evicted or expired, there's nothing to write.
+			continue
+		}
+		entries = append(entries, Entry{Key: key, Value: val})
+	}
+
+	span.SetAttributes(attribute.Int("batch_size", len(entries)))
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(entries)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error in encoding write-behind batch")
+		return err
+	}
+
+	backoff := q.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		err = q.post(ctx, body)
+		if err == nil || attempt == q.cfg.MaxRetries {
+			break
+		}
+
+		// Jittered, so nodes retrying at the same time don't hit the sink together.
+		time.Sleep(time.Duration(rand.Int63n(int64(backoff))) + backoff/2)
+		backoff *= 2
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error in posting write-behind batch")
+	}
+
+	return err
+}
+
+// post posts a single batch to the sink.
+func (q *Queue) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.cfg.Sink, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	res, err := q.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("sink responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+// Package cache is an embeddable, concurrency-safe, in-memory cache with typed keys and values.
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/internal/cache/list"
+)
+
+const (
+	// PolicyLRU evicts the least recently used key.
+	PolicyLRU Policy = iota
+
+	// PolicyFIFO evicts the oldest key, regardless of how it's used.
+	PolicyFIFO
+)
+
+const (
+	// EvictionReasonCapacity means the key was evicted to make room for another key.
+	EvictionReasonCapacity EvictionReason = iota
+
+	// EvictionReasonExpired means the key's TTL passed.
+	EvictionReasonExpired
+
+	// EvictionReasonDeleted means the key was deleted or the cache was cleared.
+	EvictionReasonDeleted
+)
+
+type (
+	// Policy determines which key is evicted when the cache is full.
+	Policy int
+
+	// EvictionReason is the reason a key left the cache.
+	EvictionReason int
+
+	// entry is a value and its expiry.
+	entry[V any] struct {
+		val       V
+		expiresAt time.Time
+	}
+
+	// eviction is an eviction which its callback hasn't been called for yet.
+	eviction[K comparable, V any] struct {
+		key    K
+		val    V
+		reason EvictionReason
+	}
+
+	// Cache is a cache of values of type V by keys of type K.
+	// Expired keys are removed lazily when they're accessed, or by DeleteExpired.
+	// It shares its eviction order with the LRU cache of Caster.
+	Cache[K comparable, V any] struct {
+		mutex   sync.Mutex
+		cfg     config[K, V]
+		entries map[K]*list.Node[K, entry[V]]
+
+		// order's head is evicted first, its tail last.
+		order *list.DoublyLinkedList[K, entry[V]]
+
+		// now returns the current time, it's replaced in tests.
+		now func() time.Time
+	}
+)
+
+// String returns the name of the policy.
+func (p Policy) String() string {
+	switch p {
+	case PolicyLRU:
+		return "lru"
+	case PolicyFIFO:
+		return "fifo"
+	default:
+		return fmt.Sprintf("policy(%d)", int(p))
+	}
+}
+
+// String returns the name of the reason.
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionReasonCapacity:
+		return "capacity"
+	case EvictionReasonExpired:
+		return "expired"
+	case EvictionReasonDeleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("reason(%d)", int(r))
+	}
+}
+
+// New returns a new cache configured by the options.
+// The options are typed by the cache's key and value types, like New[string, int](WithCapacity[string, int](100)).
+func New[K comparable, V any](opts ...Option[K, V]) *Cache[K, V] {
+	var cfg config[K, V]
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.onEvict == nil {
+		cfg.onEvict = func(K, V, EvictionReason) {}
+	}
+
+	return &Cache[K, V]{
+		cfg:     cfg,
+		entries: make(map[K]*list.Node[K, entry[V]]),
+		order:   list.NewDoublyLinkedList[K, entry[V]](),
+		now:     time.Now,
+	}
+}
+
+// Get returns the value of a key and whether it exists.
+// With the LRU policy, the key becomes the most recently used one.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	return c.get(key, c.cfg.policy == PolicyLRU)
+}
+
+// Peek returns the value of a key and whether it exists, without affecting the eviction order.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	return c.get(key, false)
+}
+
+// get returns the value of a key and moves it to the back of the eviction order if touch is true.
+func (c *Cache[K, V]) get(key K, touch bool) (V, bool) {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	node, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	if node.GetVal().expired(c.now()) {
+		evicted = append(evicted, c.remove(node, EvictionReasonExpired))
+		var zero V
+		return zero, false
+	}
+
+	if touch {
+		c.order.MoveToBack(node)
+	}
+
+	return node.GetVal().val, true
+}
+
+// Set sets the value of a key with the default TTL.
+func (c *Cache[K, V]) Set(key K, val V) {
+	c.SetWithTTL(key, val, c.cfg.ttl)
+}
+
+// SetWithTTL sets the value of a key which expires after ttl, zero means it doesn't expire.
+func (c *Cache[K, V]) SetWithTTL(key K, val V, ttl time.Duration) {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = c.now().Add(ttl)
+	}
+
+	e := entry[V]{val: val, expiresAt: expiresAt}
+	if node, ok := c.entries[key]; ok {
+		node.SetVal(e)
+		if c.cfg.policy == PolicyLRU {
+			c.order.MoveToBack(node)
+		}
+		return
+	}
+
+	c.entries[key] = c.order.AddToBack(key, e)
+
+	for c.cfg.capacity > 0 && len(c.entries) > c.cfg.capacity {
+		evicted = append(evicted, c.remove(c.order.Head(), EvictionReasonCapacity))
+	}
+}
+
+// Delete deletes a key and reports whether it existed.
+func (c *Cache[K, V]) Delete(key K) bool {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	node, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+
+	evicted = append(evicted, c.remove(node, EvictionReasonDeleted))
+
+	return true
+}
+
+// DeleteExpired deletes every expired key.
+func (c *Cache[K, V]) DeleteExpired() {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	for node := c.order.Head(); node != nil; {
+		next := node.Next()
+		if node.GetVal().expired(now) {
+			evicted = append(evicted, c.remove(node, EvictionReasonExpired))
+		}
+		node = next
+	}
+}
+
+// Clear deletes every key.
+func (c *Cache[K, V]) Clear() {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for c.order.Head() != nil {
+		evicted = append(evicted, c.remove(c.order.Head(), EvictionReasonDeleted))
+	}
+}
+
+// Len returns the number of keys, including expired keys which aren't removed yet.
+func (c *Cache[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.entries)
+}
+
+// notify calls the eviction callback for the evictions. It must be called while the cache isn't locked.
+func (c *Cache[K, V]) notify(evicted []eviction[K, V]) {
+	for _, e := range evicted {
+		c.cfg.onEvict(e.key, e.val, e.reason)
+	}
+}
+
+// remove removes the node and returns its eviction.
+func (c *Cache[K, V]) remove(node *list.Node[K, entry[V]], reason EvictionReason) eviction[K, V] {
+	c.order.Remove(node)
+	delete(c.entries, node.GetKey())
+
+	return eviction[K, V]{key: node.GetKey(), val: node.GetVal().val, reason: reason}
+}
+
+// expired determines if the entry is expired at the given time.
+func (e entry[V]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
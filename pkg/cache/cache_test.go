@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock is a clock which only moves when it's advanced.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// evictions records the evictions of a cache.
+type evictions []string
+
+func (e *evictions) record(key string, val int, reason EvictionReason) {
+	*e = append(*e, key+":"+reason.String())
+}
+
+func (e evictions) String() string {
+	return strings.Join(e, ",")
+}
+
+// newTestCache returns a cache whose clock only moves when it's advanced.
+func newTestCache(opts ...Option[string, int]) (*Cache[string, int], *evictions, *clock) {
+	var evicted evictions
+	c := New(append(opts, WithEvictionCallback(evicted.record))...)
+
+	clk := &clock{now: time.Unix(1700000000, 0)}
+	c.now = clk.Now
+
+	return c, &evicted, clk
+}
+
+func TestCacheCapacityEviction(t *testing.T) {
+	tests := []struct {
+		policy  Policy
+		evicted string
+	}{
+		// a is used after b, so b is the least recently used key.
+		{policy: PolicyLRU, evicted: "b:capacity,c:capacity"},
+		// Neither reads nor overwrites change the order, a is still the oldest key.
+		{policy: PolicyFIFO, evicted: "a:capacity,b:capacity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			c, evicted, _ := newTestCache(WithCapacity[string, int](3), WithPolicy[string, int](tt.policy))
+
+			c.Set("a", 1)
+			c.Set("b", 2)
+			c.Set("c", 3)
+			c.Get("a")
+			c.Set("a", 10)
+			c.Set("d", 4)
+			c.Set("e", 5)
+
+			if evicted.String() != tt.evicted {
+				t.Fatalf("got evictions %s, want %s", evicted, tt.evicted)
+			}
+			if n := c.Len(); n != 3 {
+				t.Errorf("got %d keys, want 3", n)
+			}
+		})
+	}
+}
+
+func TestCachePeek(t *testing.T) {
+	c, evicted, _ := newTestCache(WithCapacity[string, int](2))
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	if val, ok := c.Peek("a"); !ok || val != 1 {
+		t.Fatalf("got %d %t, want 1 true", val, ok)
+	}
+	if _, ok := c.Peek("missing"); ok {
+		t.Fatal("got a missing key")
+	}
+
+	// Peeking didn't make a the most recently used key, so it's still evicted first.
+	c.Set("c", 3)
+	if evicted.String() != "a:capacity" {
+		t.Fatalf("got evictions %s, want a:capacity", evicted)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, evicted, clk := newTestCache(WithTTL[string, int](time.Minute))
+
+	c.Set("default", 1)
+	c.SetWithTTL("short", 2, time.Second)
+	c.SetWithTTL("forever", 3, 0)
+
+	clk.Advance(time.Second)
+
+	// Expired keys are removed when they're accessed.
+	if _, ok := c.Peek("short"); ok {
+		t.Fatal("got an expired key")
+	}
+	if evicted.String() != "short:expired" {
+		t.Fatalf("got evictions %s, want short:expired", evicted)
+	}
+	if _, ok := c.Get("default"); !ok {
+		t.Fatal("got no key before its default TTL")
+	}
+
+	// Or by DeleteExpired, without being accessed.
+	clk.Advance(time.Minute)
+	if n := c.Len(); n != 2 {
+		t.Fatalf("got %d keys, want expired keys to be counted until they're removed", n)
+	}
+	c.DeleteExpired()
+	if evicted.String() != "short:expired,default:expired" {
+		t.Fatalf("got evictions %s, want short:expired,default:expired", evicted)
+	}
+
+	if val, ok := c.Get("forever"); !ok || val != 3 {
+		t.Fatalf("got %d %t for a key without TTL, want 3 true", val, ok)
+	}
+
+	c.Set("deleted", 4)
+	c.Delete("deleted")
+	c.Clear()
+	if evicted.String() != "short:expired,default:expired,deleted:deleted,forever:deleted" {
+		t.Fatalf("got evictions %s", evicted)
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("got %d keys after clear, want 0", n)
+	}
+}
+
+func TestCacheEvictionCallbackUnlocked(t *testing.T) {
+	var c *Cache[string, int]
+	var calls int
+	c = New(
+		WithCapacity[string, int](1),
+		WithEvictionCallback(func(key string, val int, reason EvictionReason) {
+			// The cache would deadlock if the callback was called while it's locked.
+			c.Len()
+			calls++
+			if key == "a" {
+				c.Set("callback", val)
+			}
+		}),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Set("a", 1)
+		c.Set("b", 2)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the eviction callback deadlocked")
+	}
+
+	if val, ok := c.Get("callback"); !ok || val != 1 {
+		t.Errorf("got %d %t, want the key set by the callback", val, ok)
+	}
+	// Setting the key from the callback evicted b.
+	if calls != 2 {
+		t.Errorf("got %d evictions, want 2", calls)
+	}
+}
@@ -0,0 +1,46 @@
+package cache
+
+import "time"
+
+type (
+	// Option configures a cache of values of type V by keys of type K.
+	Option[K comparable, V any] func(*config[K, V])
+
+	// config holds the configurations of a cache.
+	config[K comparable, V any] struct {
+		capacity int
+		ttl      time.Duration
+		policy   Policy
+		onEvict  func(key K, val V, reason EvictionReason)
+	}
+)
+
+// WithCapacity sets the maximum number of keys, zero means no limit.
+// When the cache is full, the key chosen by the eviction policy is evicted.
+func WithCapacity[K comparable, V any](capacity int) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.capacity = capacity
+	}
+}
+
+// WithTTL sets the default TTL of keys, zero means keys don't expire.
+func WithTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.ttl = ttl
+	}
+}
+
+// WithPolicy sets the eviction policy, it's LRU by default.
+func WithPolicy[K comparable, V any](policy Policy) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.policy = policy
+	}
+}
+
+// WithEvictionCallback sets the function which is called when a key leaves the cache.
+// It's called after the cache is unlocked, so it can use the cache.
+func WithEvictionCallback[K comparable, V any](fn func(key K, val V, reason EvictionReason)) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.onEvict = fn
+	}
+}
@@ -0,0 +1,373 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: caster.proto
+
+package casterpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Caster_Get_FullMethodName    = "/caster.v1.Caster/Get"
+	Caster_Set_FullMethodName    = "/caster.v1.Caster/Set"
+	Caster_Delete_FullMethodName = "/caster.v1.Caster/Delete"
+	Caster_MGet_FullMethodName   = "/caster.v1.Caster/MGet"
+	Caster_MSet_FullMethodName   = "/caster.v1.Caster/MSet"
+	Caster_Flush_FullMethodName  = "/caster.v1.Caster/Flush"
+	Caster_Watch_FullMethodName  = "/caster.v1.Caster/Watch"
+)
+
+// CasterClient is the client API for Caster service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CasterClient interface {
+	// Get gets a key. It fails with NOT_FOUND if the key doesn't exist.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Set sets a key.
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error)
+	// Delete deletes a key. It fails with NOT_FOUND if the key doesn't exist.
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// MGet gets multiple keys, which may live on different nodes.
+	MGet(ctx context.Context, in *MGetRequest, opts ...grpc.CallOption) (*MGetResponse, error)
+	// MSet sets multiple keys, which may live on different nodes. It isn't atomic.
+	MSet(ctx context.Context, in *MSetRequest, opts ...grpc.CallOption) (*MSetResponse, error)
+	// Flush clears the cache of the called node, or every node if all is true.
+	Flush(ctx context.Context, in *FlushRequest, opts ...grpc.CallOption) (*FlushResponse, error)
+	// Watch streams keyspace events of keys with the given prefixes until the call is cancelled.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Caster_WatchClient, error)
+}
+
+type casterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCasterClient(cc grpc.ClientConnInterface) CasterClient {
+	return &casterClient{cc}
+}
+
+func (c *casterClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, Caster_Get_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *casterClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error) {
+	out := new(SetResponse)
+	err := c.cc.Invoke(ctx, Caster_Set_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *casterClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, Caster_Delete_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *casterClient) MGet(ctx context.Context, in *MGetRequest, opts ...grpc.CallOption) (*MGetResponse, error) {
+	out := new(MGetResponse)
+	err := c.cc.Invoke(ctx, Caster_MGet_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *casterClient) MSet(ctx context.Context, in *MSetRequest, opts ...grpc.CallOption) (*MSetResponse, error) {
+	out := new(MSetResponse)
+	err := c.cc.Invoke(ctx, Caster_MSet_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *casterClient) Flush(ctx context.Context, in *FlushRequest, opts ...grpc.CallOption) (*FlushResponse, error) {
+	out := new(FlushResponse)
+	err := c.cc.Invoke(ctx, Caster_Flush_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *casterClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Caster_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Caster_ServiceDesc.Streams[0], Caster_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &casterWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Caster_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type casterWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *casterWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CasterServer is the server API for Caster service.
+// All implementations must embed UnimplementedCasterServer
+// for forward compatibility
+type CasterServer interface {
+	// Get gets a key. It fails with NOT_FOUND if the key doesn't exist.
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Set sets a key.
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	// Delete deletes a key. It fails with NOT_FOUND if the key doesn't exist.
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// MGet gets multiple keys, which may live on different nodes.
+	MGet(context.Context, *MGetRequest) (*MGetResponse, error)
+	// MSet sets multiple keys, which may live on different nodes. It isn't atomic.
+	MSet(context.Context, *MSetRequest) (*MSetResponse, error)
+	// Flush clears the cache of the called node, or every node if all is true.
+	Flush(context.Context, *FlushRequest) (*FlushResponse, error)
+	// Watch streams keyspace events of keys with the given prefixes until the call is cancelled.
+	Watch(*WatchRequest, Caster_WatchServer) error
+	mustEmbedUnimplementedCasterServer()
+}
+
+// UnimplementedCasterServer must be embedded to have forward compatible implementations.
+type UnimplementedCasterServer struct {
+}
+
+func (UnimplementedCasterServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedCasterServer) Set(context.Context, *SetRequest) (*SetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (UnimplementedCasterServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedCasterServer) MGet(context.Context, *MGetRequest) (*MGetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MGet not implemented")
+}
+func (UnimplementedCasterServer) MSet(context.Context, *MSetRequest) (*MSetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MSet not implemented")
+}
+func (UnimplementedCasterServer) Flush(context.Context, *FlushRequest) (*FlushResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Flush not implemented")
+}
+func (UnimplementedCasterServer) Watch(*WatchRequest, Caster_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedCasterServer) mustEmbedUnimplementedCasterServer() {}
+
+// UnsafeCasterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CasterServer will
+// result in compilation errors.
+type UnsafeCasterServer interface {
+	mustEmbedUnimplementedCasterServer()
+}
+
+func RegisterCasterServer(s grpc.ServiceRegistrar, srv CasterServer) {
+	s.RegisterService(&Caster_ServiceDesc, srv)
+}
+
+func _Caster_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CasterServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Caster_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CasterServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Caster_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CasterServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Caster_Set_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CasterServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Caster_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CasterServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Caster_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CasterServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Caster_MGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CasterServer).MGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Caster_MGet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CasterServer).MGet(ctx, req.(*MGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Caster_MSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CasterServer).MSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Caster_MSet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CasterServer).MSet(ctx, req.(*MSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Caster_Flush_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlushRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CasterServer).Flush(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Caster_Flush_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CasterServer).Flush(ctx, req.(*FlushRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Caster_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CasterServer).Watch(m, &casterWatchServer{stream})
+}
+
+type Caster_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type casterWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *casterWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Caster_ServiceDesc is the grpc.ServiceDesc for Caster service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Caster_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "caster.v1.Caster",
+	HandlerType: (*CasterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _Caster_Get_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _Caster_Set_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Caster_Delete_Handler,
+		},
+		{
+			MethodName: "MGet",
+			Handler:    _Caster_MGet_Handler,
+		},
+		{
+			MethodName: "MSet",
+			Handler:    _Caster_MSet_Handler,
+		},
+		{
+			MethodName: "Flush",
+			Handler:    _Caster_Flush_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Caster_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "caster.proto",
+}
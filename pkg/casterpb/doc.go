@@ -0,0 +1,4 @@
+// Package casterpb is the generated gRPC API of Caster, defined in api/caster.proto.
+package casterpb
+
+//go:generate protoc -I ../../api --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative caster.proto
@@ -0,0 +1,496 @@
+// Package client is a cluster-aware Go client of Caster.
+// It sends every request directly to the node which owns its key, so requests don't take an extra hop.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mojixcoder/caster/pkg/keyhash"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// redirectHeader asks nodes to redirect requests of keys they don't own instead of forwarding them.
+	redirectHeader = "X-Caster-Redirect"
+
+	// maxRedirects is the maximum number of redirects followed by a request.
+	maxRedirects = 2
+)
+
+const (
+	// AlgorithmTokenBucket refills the limit evenly during the window and allows bursts of up to the limit.
+	AlgorithmTokenBucket = "token_bucket"
+
+	// AlgorithmSlidingWindow allows the limit in any window.
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+const (
+	// TxOpSet sets a key.
+	TxOpSet = "set"
+
+	// TxOpDelete deletes a key.
+	TxOpDelete = "delete"
+
+	// TxOpCheck only checks the conditions of a key.
+	TxOpCheck = "check"
+)
+
+type (
+	// Client is a Caster client. It's safe for concurrent use.
+	// It follows redirects of nodes which don't own a key and refreshes its topology when the cluster changes.
+	Client struct {
+		mutex *sync.RWMutex
+
+		// nodes are the addresses of the cluster nodes, ordered by their indexes.
+		nodes []string
+
+		// epoch is the topology epoch of nodes, zero if the topology isn't fetched from the cluster.
+		epoch uint64
+
+		http    *http.Client
+		retries int
+		backoff time.Duration
+	}
+
+	// TxOp is an operation of a transaction, it's applied only if all of its conditions hold.
+	TxOp struct {
+		Op      string
+		Key     string
+		Value   any
+		SoftTTL time.Duration
+		HardTTL time.Duration
+
+		// IfExists requires the key to exist or not.
+		IfExists *bool
+		// IfVersion requires the key to have the version, zero requires the key not to exist.
+		IfVersion *uint64
+		// IfValue requires the key to hold the value, nil means no condition.
+		IfValue any
+	}
+
+	// RateLimitRequest is a request to spend cost from a rate limiter.
+	RateLimitRequest struct {
+		Key string
+		// Algorithm is either AlgorithmTokenBucket or AlgorithmSlidingWindow, AlgorithmTokenBucket by default.
+		Algorithm string
+		Limit     int64
+		Window    time.Duration
+		// Cost is the cost of the request, 1 if it's zero.
+		Cost int64
+		// CheckOnly only checks the limiter without spending from it, Cost is ignored.
+		CheckOnly bool
+	}
+
+	// RateLimitResult is the result of a rate limiter.
+	RateLimitResult struct {
+		Allowed    bool
+		Remaining  int64
+		RetryAfter time.Duration
+	}
+
+	getResponse struct {
+		Value json.RawMessage `json:"value"`
+	}
+
+	setRequest struct {
+		Key     string `json:"key"`
+		Value   any    `json:"value"`
+		SoftTTL int64  `json:"soft_ttl_ms,omitempty"`
+		HardTTL int64  `json:"hard_ttl_ms,omitempty"`
+	}
+
+	deleteRequest struct {
+		Key string `json:"key"`
+	}
+
+	txOp struct {
+		Op        string          `json:"op"`
+		Key       string          `json:"key"`
+		Value     any             `json:"value,omitempty"`
+		SoftTTL   int64           `json:"soft_ttl_ms,omitempty"`
+		HardTTL   int64           `json:"hard_ttl_ms,omitempty"`
+		IfExists  *bool           `json:"if_exists,omitempty"`
+		IfVersion *uint64         `json:"if_version,omitempty"`
+		IfValue   json.RawMessage `json:"if_value,omitempty"`
+	}
+
+	txRequest struct {
+		Ops []txOp `json:"ops"`
+	}
+
+	txResponse struct {
+		Versions []uint64 `json:"versions"`
+	}
+
+	rateLimitRequest struct {
+		Key       string `json:"key"`
+		Algorithm string `json:"algorithm,omitempty"`
+		Limit     int64  `json:"limit"`
+		Window    int64  `json:"window_ms"`
+		Cost      *int64 `json:"cost,omitempty"`
+	}
+
+	rateLimitResponse struct {
+		Allowed    bool  `json:"allowed"`
+		Remaining  int64 `json:"remaining"`
+		RetryAfter int64 `json:"retry_after_ms"`
+	}
+
+	errorResponse struct {
+		Message string `json:"message"`
+	}
+
+	movedResponse struct {
+		Address string `json:"address"`
+		Epoch   uint64 `json:"epoch"`
+	}
+
+	topologyResponse struct {
+		Epoch uint64 `json:"epoch"`
+		Nodes []struct {
+			Index   int    `json:"index"`
+			Address string `json:"address"`
+		} `json:"nodes"`
+	}
+)
+
+// New returns a new client of the cluster with the given node addresses, ordered by the nodes' indexes.
+func New(nodes []string, opts ...Option) (*Client, error) {
+	if len(nodes) == 0 {
+		return nil, ErrNoNodes
+	}
+
+	cfg := config{
+		timeout:      defaultTimeout,
+		retries:      defaultRetries,
+		backoff:      defaultBackoff,
+		maxIdleConns: defaultMaxIdleConns,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	httpClient := cfg.httpClient
+	if httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConns = cfg.maxIdleConns * len(nodes)
+		transport.MaxIdleConnsPerHost = cfg.maxIdleConns
+
+		httpClient = &http.Client{Transport: transport, Timeout: cfg.timeout}
+	}
+
+	addresses := make([]string, len(nodes))
+	for i, node := range nodes {
+		addresses[i] = strings.TrimRight(node, "/")
+	}
+
+	return &Client{
+		mutex:   new(sync.RWMutex),
+		nodes:   addresses,
+		http:    httpClient,
+		retries: cfg.retries,
+		backoff: cfg.backoff,
+	}, nil
+}
+
+// Dial returns a new client of the cluster which the seed node is a member of.
+// The cluster's topology is fetched from the seed node.
+func Dial(ctx context.Context, seed string, opts ...Option) (*Client, error) {
+	c, err := New([]string{seed}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Node returns the address of the node which owns the key.
+func (c *Client) Node(key string) string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.nodes[keyhash.Index(key, len(c.nodes))]
+}
+
+// Epoch returns the topology epoch known to the client, zero if the topology isn't fetched from the cluster.
+func (c *Client) Epoch() uint64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.epoch
+}
+
+// Refresh fetches the topology of the cluster from the first known node which responds.
+func (c *Client) Refresh(ctx context.Context) error {
+	c.mutex.RLock()
+	nodes := append([]string(nil), c.nodes...)
+	c.mutex.RUnlock()
+
+	var errs []error
+	for _, node := range nodes {
+		err := c.refreshFrom(ctx, node)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// refreshFrom fetches the topology of the cluster from the node.
+func (c *Client) refreshFrom(ctx context.Context, node string) error {
+	var res topologyResponse
+	if err := c.attempt(ctx, http.MethodGet, node+"/cluster/topology", nil, &res); err != nil {
+		return err
+	}
+
+	nodes := make([]string, len(res.Nodes))
+	for i, n := range res.Nodes {
+		if n.Index != i {
+			return fmt.Errorf("caster: node indexes must be 0 to %d, got %d", len(res.Nodes)-1, n.Index)
+		}
+		nodes[i] = strings.TrimRight(n.Address, "/")
+	}
+
+	if len(nodes) == 0 {
+		return ErrNoNodes
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.nodes = nodes
+	c.epoch = res.Epoch
+
+	return nil
+}
+
+// Get gets the value of a key and decodes it into out, which can be nil.
+// It returns ErrNotFound if the key doesn't exist.
+func (c *Client) Get(ctx context.Context, key string, out any) error {
+	var res getResponse
+	query := url.Values{"key": {key}}
+	if err := c.do(ctx, http.MethodGet, c.Node(key), "/get?"+query.Encode(), nil, &res, true); err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(res.Value, out)
+}
+
+// Set sets the value of a key which doesn't expire.
+func (c *Client) Set(ctx context.Context, key string, val any) error {
+	return c.SetWithTTL(ctx, key, val, 0, 0)
+}
+
+// SetWithTTL sets the value of a key which goes stale after softTTL and expires after hardTTL.
+// Zero TTLs mean the key doesn't go stale or expire.
+func (c *Client) SetWithTTL(ctx context.Context, key string, val any, softTTL, hardTTL time.Duration) error {
+	req := setRequest{
+		Key:     key,
+		Value:   val,
+		SoftTTL: softTTL.Milliseconds(),
+		HardTTL: hardTTL.Milliseconds(),
+	}
+	return c.do(ctx, http.MethodPost, c.Node(key), "/set", &req, nil, true)
+}
+
+// Delete deletes a key. It returns ErrNotFound if the key doesn't exist.
+// If it's retried, ErrNotFound isn't returned, as an earlier attempt whose response was lost may have deleted the key.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	retried, err := c.send(ctx, http.MethodPost, c.Node(key), "/delete", &deleteRequest{Key: key}, nil, true)
+	if retried && errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Tx applies the operations atomically and returns the versions of their keys afterwards.
+// The keys must live on the same node, hash tags can be used to make sure they do.
+// It returns ErrConflict if a condition fails, in which case nothing is applied.
+func (c *Client) Tx(ctx context.Context, ops []TxOp) ([]uint64, error) {
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	req := txRequest{Ops: make([]txOp, len(ops))}
+	for i, op := range ops {
+		req.Ops[i] = txOp{
+			Op:        op.Op,
+			Key:       op.Key,
+			Value:     op.Value,
+			SoftTTL:   op.SoftTTL.Milliseconds(),
+			HardTTL:   op.HardTTL.Milliseconds(),
+			IfExists:  op.IfExists,
+			IfVersion: op.IfVersion,
+		}
+
+		if op.IfValue != nil {
+			val, err := json.Marshal(op.IfValue)
+			if err != nil {
+				return nil, err
+			}
+			req.Ops[i].IfValue = val
+		}
+	}
+
+	var res txResponse
+	if err := c.do(ctx, http.MethodPost, c.Node(ops[0].Key), "/tx", &req, &res, false); err != nil {
+		return nil, err
+	}
+
+	return res.Versions, nil
+}
+
+// RateLimit spends cost from a rate limiter if it's allowed, or only checks the limiter if the request is CheckOnly.
+func (c *Client) RateLimit(ctx context.Context, r RateLimitRequest) (RateLimitResult, error) {
+	req := rateLimitRequest{
+		Key:       r.Key,
+		Algorithm: r.Algorithm,
+		Limit:     r.Limit,
+		Window:    r.Window.Milliseconds(),
+	}
+
+	// A zero cost is left to the node, which spends 1 by default.
+	switch {
+	case r.CheckOnly:
+		req.Cost = new(int64)
+	case r.Cost != 0:
+		req.Cost = &r.Cost
+	}
+
+	var res rateLimitResponse
+	if err := c.do(ctx, http.MethodPost, c.Node(r.Key), "/ratelimit", &req, &res, false); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return RateLimitResult{
+		Allowed:    res.Allowed,
+		Remaining:  res.Remaining,
+		RetryAfter: time.Duration(res.RetryAfter) * time.Millisecond,
+	}, nil
+}
+
+// do sends a request to the node and decodes the response into out, which can be nil.
+// Idempotent requests are retried with jittered exponential backoff after network errors and temporary failures.
+func (c *Client) do(ctx context.Context, method, node, path string, in, out any, idempotent bool) error {
+	_, err := c.send(ctx, method, node, path, in, out, idempotent)
+	return err
+}
+
+// send is like do, but it also reports whether the request was retried.
+func (c *Client) send(ctx context.Context, method, node, path string, in, out any, idempotent bool) (bool, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return false, err
+		}
+	}
+
+	retries := 0
+	if idempotent {
+		retries = c.retries
+	}
+
+	var err error
+	for attempt, redirects := 0, 0; ; attempt++ {
+		err = c.attempt(ctx, method, node+path, body, out)
+
+		// The node didn't handle the request, so following the redirect isn't a retry.
+		var moved *movedError
+		if errors.As(err, &moved) && redirects < maxRedirects {
+			if moved.epoch != c.Epoch() {
+				_ = c.refreshFrom(ctx, moved.address)
+			}
+			node = moved.address
+			redirects++
+			attempt--
+			continue
+		}
+
+		if err == nil || attempt >= retries || ctx.Err() != nil || !retryable(err) {
+			return attempt > 0, err
+		}
+
+		backoff := c.backoff << attempt
+		backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt > 0, err
+		}
+	}
+}
+
+// attempt sends a request once.
+func (c *Client) attempt(ctx context.Context, method, address string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(redirectHeader, "true")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusMisdirectedRequest {
+		var body movedResponse
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			return err
+		}
+		return &movedError{address: strings.TrimRight(body.Address, "/"), epoch: body.Epoch}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var body errorResponse
+		_ = json.NewDecoder(res.Body).Decode(&body)
+		if body.Message == "" {
+			body.Message = http.StatusText(res.StatusCode)
+		}
+		return &Error{StatusCode: res.StatusCode, Message: body.Message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// retryable determines if a failed request may succeed if it's retried.
+func retryable(err error) bool {
+	var resErr *Error
+	if errors.As(err, &resErr) {
+		return resErr.temporary()
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
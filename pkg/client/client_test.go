@@ -0,0 +1,337 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mojixcoder/caster/pkg/keyhash"
+)
+
+// testNode is a fake node which records the keys of its requests and answers them with handler.
+type testNode struct {
+	*httptest.Server
+
+	mutex *sync.Mutex
+	keys  []string
+}
+
+// newTestNode returns a node which answers with handler, or with an empty response if handler is nil.
+func newTestNode(t *testing.T, handler http.HandlerFunc) *testNode {
+	n := &testNode{mutex: new(sync.Mutex)}
+	n.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		if key == "" && r.Body != nil {
+			var body struct {
+				Key string `json:"key"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			key = body.Key
+		}
+
+		n.mutex.Lock()
+		n.keys = append(n.keys, key)
+		n.mutex.Unlock()
+
+		if handler == nil {
+			w.Write([]byte(`{"message":"ok"}`))
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(n.Close)
+
+	return n
+}
+
+// Keys returns the keys which the node has received.
+func (n *testNode) Keys() []string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return append([]string(nil), n.keys...)
+}
+
+// newTestClient returns a client of the nodes whose retries don't wait.
+func newTestClient(t *testing.T, nodes ...string) *Client {
+	t.Helper()
+
+	c, err := New(nodes, WithBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// dropConn closes the connection of the request without a response.
+func dropConn(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func TestClientRoutesToOwner(t *testing.T) {
+	nodes := []*testNode{newTestNode(t, nil), newTestNode(t, nil), newTestNode(t, nil)}
+	c := newTestClient(t, nodes[0].URL, nodes[1].URL, nodes[2].URL+"/")
+
+	ctx := context.Background()
+	want := make([][]string, len(nodes))
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "{user}.name", "{user}.age"} {
+		index := keyhash.Index(key, len(nodes))
+		if node := c.Node(key); node != nodes[index].URL {
+			t.Fatalf("got node %s for %q, want %s", node, key, nodes[index].URL)
+		}
+
+		if err := c.Set(ctx, key, 1); err != nil {
+			t.Fatalf("got %v from setting %q", err, key)
+		}
+		if err := c.Get(ctx, key, nil); err != nil {
+			t.Fatalf("got %v from getting %q", err, key)
+		}
+		want[index] = append(want[index], key, key)
+	}
+
+	for i, node := range nodes {
+		if got := strings.Join(node.Keys(), ","); got != strings.Join(want[i], ",") {
+			t.Errorf("got keys %q on node %d, want %q", got, i, strings.Join(want[i], ","))
+		}
+	}
+}
+
+func TestClientFollowsRedirects(t *testing.T) {
+	owner := newTestNode(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(redirectHeader) != "true" {
+			t.Errorf("got no %s header", redirectHeader)
+		}
+
+		switch r.URL.Path {
+		case "/cluster/topology":
+			json.NewEncoder(w).Encode(map[string]any{
+				"epoch": 7,
+				"nodes": []map[string]any{{"index": 0, "address": "http://" + r.Host}},
+			})
+		default:
+			w.Write([]byte(`{"value":"owned"}`))
+		}
+	})
+
+	old := newTestNode(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMisdirectedRequest)
+		json.NewEncoder(w).Encode(map[string]any{"message": "moved", "index": 0, "address": owner.URL, "epoch": 7})
+	})
+
+	c := newTestClient(t, old.URL)
+
+	var val string
+	if err := c.Get(context.Background(), "key", &val); err != nil {
+		t.Fatalf("got %v", err)
+	}
+	if val != "owned" {
+		t.Fatalf("got %q, want the value of the owner", val)
+	}
+
+	// The redirect has a new epoch, so the topology is refreshed from the owner.
+	if epoch := c.Epoch(); epoch != 7 {
+		t.Errorf("got epoch %d, want 7", epoch)
+	}
+	if node := c.Node("key"); node != owner.URL {
+		t.Errorf("got node %s after the refresh, want %s", node, owner.URL)
+	}
+	if keys := old.Keys(); len(keys) != 1 {
+		t.Errorf("got %d requests to the old node, want 1", len(keys))
+	}
+}
+
+func TestClientRedirectLoop(t *testing.T) {
+	var node *testNode
+	node = newTestNode(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMisdirectedRequest)
+		json.NewEncoder(w).Encode(map[string]any{"address": node.URL})
+	})
+
+	c := newTestClient(t, node.URL)
+
+	var moved *movedError
+	if err := c.Get(context.Background(), "key", nil); !errors.As(err, &moved) {
+		t.Fatalf("got %v, want a redirect error", err)
+	}
+	if requests := len(node.Keys()); requests != maxRedirects+1 {
+		t.Errorf("got %d requests, want %d", requests, maxRedirects+1)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int32
+		do       func(c *Client) error
+		requests int
+		ok       bool
+	}{
+		{name: "get recovers", failures: 2, do: func(c *Client) error { return c.Get(context.Background(), "key", nil) }, requests: 3, ok: true},
+		{name: "get exhausts retries", failures: 3, do: func(c *Client) error { return c.Get(context.Background(), "key", nil) }, requests: 3},
+		{name: "set recovers", failures: 1, do: func(c *Client) error { return c.Set(context.Background(), "key", 1) }, requests: 2, ok: true},
+		{name: "tx isn't retried", failures: 1, do: func(c *Client) error {
+			_, err := c.Tx(context.Background(), []TxOp{{Op: TxOpSet, Key: "key", Value: 1}})
+			return err
+		}, requests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			node := newTestNode(t, func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= tt.failures {
+					dropConn(w)
+					return
+				}
+				w.Write([]byte(`{"value":1,"versions":[1]}`))
+			})
+
+			err := tt.do(newTestClient(t, node.URL))
+			if tt.ok && err != nil {
+				t.Fatalf("got %v, want it to succeed", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("got no error")
+			}
+
+			if n := len(node.Keys()); n != tt.requests {
+				t.Errorf("got %d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+}
+
+func TestClientUnreachableNode(t *testing.T) {
+	node := httptest.NewServer(http.NotFoundHandler())
+	address := node.URL
+	node.Close()
+
+	c := newTestClient(t, address)
+
+	var urlErr *url.Error
+	if err := c.Get(context.Background(), "key", nil); !errors.As(err, &urlErr) {
+		t.Fatalf("got %v, want a network error", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	tests := []struct {
+		status   int
+		body     string
+		notFound bool
+		conflict bool
+		message  string
+	}{
+		{status: http.StatusNotFound, body: `{"message":"not found"}`, notFound: true, message: "not found"},
+		{status: http.StatusConflict, body: `{"message":"condition of op 0 on key \"a\" failed"}`, conflict: true, message: `condition of op 0 on key "a" failed`},
+		{status: http.StatusBadRequest, body: `{"message":"key is required."}`, message: "key is required."},
+		{status: http.StatusInternalServerError, body: "oops", message: http.StatusText(http.StatusInternalServerError)},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			node := newTestNode(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			err := newTestClient(t, node.URL).Get(context.Background(), "key", nil)
+
+			var resErr *Error
+			if !errors.As(err, &resErr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if resErr.StatusCode != tt.status || resErr.Message != tt.message {
+				t.Errorf("got status %d and message %q, want %d and %q", resErr.StatusCode, resErr.Message, tt.status, tt.message)
+			}
+			if errors.Is(err, ErrNotFound) != tt.notFound {
+				t.Errorf("got errors.Is(err, ErrNotFound) = %t, want %t", !tt.notFound, tt.notFound)
+			}
+			if errors.Is(err, ErrConflict) != tt.conflict {
+				t.Errorf("got errors.Is(err, ErrConflict) = %t, want %t", !tt.conflict, tt.conflict)
+			}
+		})
+	}
+}
+
+func TestClientDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		lostResp bool
+		err      error
+	}{
+		{name: "not found", err: ErrNotFound},
+		{name: "not found after a lost response", lostResp: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			node := newTestNode(t, func(w http.ResponseWriter, r *http.Request) {
+				// The first attempt deletes the key, but its response is lost.
+				if requests.Add(1) == 1 && tt.lostResp {
+					dropConn(w)
+					return
+				}
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message":"not found"}`))
+			})
+
+			if err := newTestClient(t, node.URL).Delete(context.Background(), "key"); !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestClientRateLimitCost(t *testing.T) {
+	tests := []struct {
+		name string
+		req  RateLimitRequest
+		cost string
+	}{
+		{name: "default", req: RateLimitRequest{Key: "key", Limit: 10, Window: time.Second}},
+		{name: "cost", req: RateLimitRequest{Key: "key", Limit: 10, Window: time.Second, Cost: 3}, cost: "3"},
+		{name: "check only", req: RateLimitRequest{Key: "key", Limit: 10, Window: time.Second, Cost: 3, CheckOnly: true}, cost: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies := make(chan map[string]json.RawMessage, 1)
+			node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]json.RawMessage
+				json.NewDecoder(r.Body).Decode(&body)
+				bodies <- body
+				w.Write([]byte(`{"allowed":true,"remaining":9}`))
+			}))
+			defer node.Close()
+
+			res, err := newTestClient(t, node.URL).RateLimit(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("got %v", err)
+			}
+			if !res.Allowed || res.Remaining != 9 {
+				t.Errorf("got %+v", res)
+			}
+
+			body := <-bodies
+			if cost := string(body["cost"]); cost != tt.cost {
+				t.Errorf("got cost %q, want %q", cost, tt.cost)
+			}
+			if window := string(body["window_ms"]); window != "1000" {
+				t.Errorf("got window %q, want 1000", window)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNotFound is returned when a key doesn't exist.
+	ErrNotFound = errors.New("caster: not found")
+
+	// ErrConflict is returned when a key already exists or a condition of a transaction fails.
+	ErrConflict = errors.New("caster: conflict")
+
+	// ErrNoNodes is returned when a client is created without nodes.
+	ErrNoNodes = errors.New("caster: at least a node is required")
+)
+
+// Error is an error response of a node.
+// It matches ErrNotFound and ErrConflict with errors.Is based on its status code.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("caster: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// Is reports whether the error matches target.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	default:
+		return false
+	}
+}
+
+// temporary determines if the request may succeed if it's retried.
+func (e *Error) temporary() bool {
+	switch e.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// movedError is returned when a node redirects a request to the owner of its key.
+type movedError struct {
+	address string
+	epoch   uint64
+}
+
+// Error implements the error interface.
+func (e *movedError) Error() string {
+	return fmt.Sprintf("caster: moved to %s (epoch %d)", e.address, e.epoch)
+}
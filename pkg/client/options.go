@@ -0,0 +1,63 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultRetries      = 2
+	defaultBackoff      = 50 * time.Millisecond
+	defaultMaxIdleConns = 32
+)
+
+type (
+	// Option configures a client.
+	Option func(*config)
+
+	// config holds the configurations of a client.
+	config struct {
+		httpClient   *http.Client
+		timeout      time.Duration
+		retries      int
+		backoff      time.Duration
+		maxIdleConns int
+	}
+)
+
+// WithHTTPClient sets the HTTP client, which overrides the timeout and connection pool options.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = client
+	}
+}
+
+// WithTimeout sets the timeout of every attempt of a request, 5s by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries sets how many times idempotent requests are retried after network errors
+// and temporary failures, 2 by default. Zero disables retries.
+func WithRetries(retries int) Option {
+	return func(c *config) {
+		c.retries = retries
+	}
+}
+
+// WithBackoff sets the base delay between retries, it's doubled after every retry. It's 50ms by default.
+func WithBackoff(backoff time.Duration) Option {
+	return func(c *config) {
+		c.backoff = backoff
+	}
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections kept to every node, 32 by default.
+func WithMaxIdleConns(n int) Option {
+	return func(c *config) {
+		c.maxIdleConns = n
+	}
+}
@@ -0,0 +1,48 @@
+// Package keyhash maps keys to the indexes of cluster nodes.
+// It's shared by the server and clients, so both agree on the owner of every key.
+package keyhash
+
+import "strings"
+
+const (
+	// Algorithm is the name of the hash algorithm.
+	Algorithm = "fnv32a mod n"
+
+	offset32 = 2166136261
+	prime32  = 16777619
+)
+
+// HashTag returns the part of the key which is hashed to find its node.
+// If the key contains a non-empty hash tag, e.g. user42 in {user42}:profile, only the hash tag is hashed,
+// so keys with the same hash tag live on the same node. Otherwise the whole key is hashed.
+func HashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start == -1 {
+		return key
+	}
+
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+
+	return key[start+1 : start+1+end]
+}
+
+// Sum returns the 32-bit FNV-1a hash of s.
+func Sum(s string) uint32 {
+	hash := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint32(s[i])
+		hash *= prime32
+	}
+	return hash
+}
+
+// Index returns the index of the node which owns the key in a cluster of n nodes.
+func Index(key string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	return int(Sum(HashTag(key)) % uint32(n))
+}